@@ -0,0 +1,477 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// accountsFile is the database of the user accounts and their login sessions
+const accountsFile = "data/accounts.db"
+
+// loginMaxAge is how long a login lasts without logging in again
+const loginMaxAge = 30 * 24 * time.Hour
+
+// bcrypt only reads the first 72 bytes of a password, so longer ones are refused
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// passwordCost is the bcrypt work factor of new password hashes. Hashes keep their
+// own cost, so it can be raised without invalidating existing passwords.
+var passwordCost = 12
+
+// expiredFormMessage answers forms posted without a valid CSRF token
+const expiredFormMessage = "Your form has expired. Please reload the page and try again."
+
+var (
+	errInvalidUsername = errors.New("Usernames are 3 to 32 characters: letters, digits, dots, dashes and underscores")
+	errInvalidPassword = fmt.Errorf("Passwords are %d to %d characters long", minPasswordLength, maxPasswordLength)
+	errUsernameTaken   = errors.New("This username is already taken")
+	errBadCredentials  = errors.New("Invalid username or password")
+)
+
+// Buckets of the accounts database
+var (
+	accountsBucket = []byte("accounts")
+	sessionsBucket = []byte("sessions")
+)
+
+// Account is a registered user
+type Account struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Created      time.Time `json:"created"`
+}
+
+// loginSession ties a browser session to an account until it expires
+type loginSession struct {
+	Username string    `json:"username"`
+	Expires  time.Time `json:"expires"`
+}
+
+// AccountPageData holds the login and registration forms
+type AccountPageData struct {
+	Register bool
+	Username string
+	Error    string
+	CSRF     string
+}
+
+// accountStore keeps the accounts and login sessions in a bbolt database, opened on
+// first use. Sessions are stored by the SHA-256 of their ID, so the database does
+// not hold usable cookies.
+type accountStore struct {
+	mu   sync.Mutex
+	path string
+	db   *bolt.DB
+}
+
+var accounts = newAccountStore(accountsFile)
+
+func newAccountStore(path string) *accountStore {
+	return &accountStore{path: path}
+}
+
+// open opens the database and creates its buckets the first time it is called
+func (s *accountStore) open() (*bolt.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		return s.db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{accountsBucket, sessionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.db = db
+	return db, nil
+}
+
+// Close closes the database if it was opened
+func (s *accountStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// update runs a read-write transaction on the database
+func (s *accountStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	return db.Update(fn)
+}
+
+// view runs a read-only transaction on the database. Until the database is
+// created, by the first account, there is nothing to read.
+func (s *accountStore) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	opened := s.db != nil
+	s.mu.Unlock()
+	if _, err := os.Stat(s.path); !opened && os.IsNotExist(err) {
+		return nil
+	}
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	return db.View(fn)
+}
+
+// normalizeUsername lower cases a username and checks its characters
+func normalizeUsername(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 || len(name) > 32 {
+		return "", errInvalidUsername
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return "", errInvalidUsername
+		}
+	}
+	return name, nil
+}
+
+// Register creates an account and returns its normalized username
+func (s *accountStore) Register(username, password string) (string, error) {
+	name, err := normalizeUsername(username)
+	if err != nil {
+		return "", err
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", errInvalidPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(Account{Username: name, PasswordHash: hash, Created: time.Now().UTC()})
+	if err != nil {
+		return "", err
+	}
+
+	err = s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountsBucket)
+		if b.Get([]byte(name)) != nil {
+			return errUsernameTaken
+		}
+		return b.Put([]byte(name), data)
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Authenticate checks a username and password, returning the normalized username.
+// Unknown users are checked against a dummy hash so they take as long as wrong
+// passwords.
+func (s *accountStore) Authenticate(username, password string) (string, error) {
+	name, _ := normalizeUsername(username)
+	var account Account
+	found := false
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(accountsBucket).Get([]byte(name))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &account)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		verifyPassword(password, dummyPasswordHash())
+		return "", errBadCredentials
+	}
+	if !verifyPassword(password, account.PasswordHash) {
+		return "", errBadCredentials
+	}
+	return name, nil
+}
+
+// Login ties a browser session to an account and drops the expired sessions
+func (s *accountStore) Login(session, username string) error {
+	now := time.Now()
+	data, err := json.Marshal(loginSession{Username: username, Expires: now.Add(loginMaxAge).UTC()})
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		var expired [][]byte
+		err := b.ForEach(func(key, value []byte) error {
+			var login loginSession
+			if json.Unmarshal(value, &login) != nil || now.After(login.Expires) {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(sessionKey(session)), data)
+	})
+}
+
+// Logout ends the login of a browser session
+func (s *accountStore) Logout(session string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sessionKey(session)))
+	})
+}
+
+// User returns the account logged in on a browser session, or ""
+func (s *accountStore) User(session string) string {
+	if session == "" {
+		return ""
+	}
+	var login loginSession
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(sessionKey(session)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &login)
+	})
+	if err != nil {
+		log.Printf("Error reading the login session: %v", err)
+		return ""
+	}
+	if login.Username == "" || time.Now().After(login.Expires) {
+		return ""
+	}
+	return login.Username
+}
+
+// sessionKey is the key of a login session in the store
+func sessionKey(session string) string {
+	sum := sha256.Sum256([]byte(session))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword hashes a password with bcrypt at the current work factor
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	return string(hash), err
+}
+
+// verifyPassword compares a password with a hash from hashPassword in constant time
+func verifyPassword(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash returns a hash with the current work factor, to check the
+// passwords of unknown users against
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hashPassword("")
+	})
+	return dummyHash
+}
+
+// currentUser returns the account logged in on the browser, or ""
+func currentUser(r *http.Request) string {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || !validSessionID(c.Value) {
+		return ""
+	}
+	return accounts.User(c.Value)
+}
+
+// startLogin logs a browser in. The session ID is replaced so an ID planted before
+// the login cannot be used to ride it, and the favorites kept by the old session
+// are added to those of the account.
+func startLogin(w http.ResponseWriter, r *http.Request, username string) error {
+	old, _ := browserSession(w, r, false)
+	session, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	if err := accounts.Login(session, username); err != nil {
+		return err
+	}
+	setSessionCookie(w, session)
+	if old != "" {
+		if err := favorites.Merge("session:"+old, "user:"+username); err != nil {
+			log.Printf("Error merging the favorites of %s: %v", username, err)
+		}
+	}
+	return nil
+}
+
+// allowForm answers methods other than GET and POST on form pages
+func allowForm(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD, POST")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// renderAccountForm renders the login or registration form
+func renderAccountForm(w http.ResponseWriter, r *http.Request, status int, data AccountPageData) {
+	_, data.CSRF = pageSession(w, r)
+	renderTemplate(w, status, "templates/account.html", data)
+}
+
+// RegisterHandler handles the /register route: GET shows the form and POST creates
+// the account and logs it in
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowForm(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		renderAccountForm(w, r, http.StatusOK, AccountPageData{Register: true})
+		return
+	}
+
+	session, _ := browserSession(w, r, false)
+	if !checkCSRF(r, session) {
+		ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+		return
+	}
+	data := AccountPageData{Register: true, Username: r.PostFormValue("username")}
+	password := r.PostFormValue("password")
+	if password != r.PostFormValue("confirm") {
+		data.Error = "The passwords do not match"
+		renderAccountForm(w, r, http.StatusBadRequest, data)
+		return
+	}
+
+	name, err := accounts.Register(data.Username, password)
+	switch {
+	case errors.Is(err, errUsernameTaken):
+		data.Error = err.Error()
+		renderAccountForm(w, r, http.StatusConflict, data)
+		return
+	case errors.Is(err, errInvalidUsername), errors.Is(err, errInvalidPassword):
+		data.Error = err.Error()
+		renderAccountForm(w, r, http.StatusBadRequest, data)
+		return
+	}
+	if err == nil {
+		err = startLogin(w, r, name)
+	}
+	if err != nil {
+		log.Printf("Error registering %s: %v", data.Username, err)
+		ErrorHandler(w, "Your account could not be created. Please try again later.", http.StatusInternalServerError, true, true)
+		return
+	}
+	http.Redirect(w, r, "/favorites", http.StatusSeeOther)
+}
+
+// LoginHandler handles the /login route: GET shows the form and POST logs in
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowForm(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		renderAccountForm(w, r, http.StatusOK, AccountPageData{})
+		return
+	}
+
+	session, _ := browserSession(w, r, false)
+	if !checkCSRF(r, session) {
+		ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+		return
+	}
+	data := AccountPageData{Username: r.PostFormValue("username")}
+	keys := loginKeys(r, data.Username)
+	if wait := loginAttempts.Wait(keys, time.Now()); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+		data.Error = retryMessage(wait)
+		renderAccountForm(w, r, http.StatusTooManyRequests, data)
+		return
+	}
+	name, err := accounts.Authenticate(data.Username, r.PostFormValue("password"))
+	if errors.Is(err, errBadCredentials) {
+		loginAttempts.Fail(keys, time.Now())
+		data.Error = err.Error()
+		renderAccountForm(w, r, http.StatusUnauthorized, data)
+		return
+	}
+	if err == nil {
+		loginAttempts.Succeed(name)
+		err = startLogin(w, r, name)
+	}
+	if err != nil {
+		log.Printf("Error logging in %s: %v", data.Username, err)
+		ErrorHandler(w, "You could not be logged in. Please try again later.", http.StatusInternalServerError, true, true)
+		return
+	}
+	http.Redirect(w, r, "/favorites", http.StatusSeeOther)
+}
+
+// LogoutHandler handles the logout form, ending the login and starting a new
+// anonymous session
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := browserSession(w, r, false)
+	if !checkCSRF(r, session) {
+		ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+		return
+	}
+	if err := accounts.Logout(session); err != nil {
+		log.Printf("Error logging out: %v", err)
+	}
+	if id, err := newSessionID(); err == nil {
+		setSessionCookie(w, id)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// withTempAccounts points the account store at a temporary database for one test
+// and lowers the work factor so hashing does not slow the tests down
+func withTempAccounts(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "accounts.db")
+	previous, cost := accounts, passwordCost
+	accounts = newAccountStore(path)
+	passwordCost = bcrypt.MinCost
+	t.Cleanup(func() {
+		accounts.Close()
+		accounts, passwordCost = previous, cost
+	})
+	return path
+}
+
+// postForm submits a form with the CSRF token of the session
+func postForm(handler http.HandlerFunc, target string, cookie *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	form.Set(csrfField, csrfToken(cookie.Value))
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// sessionFrom returns the session cookie set by a response
+func sessionFrom(t *testing.T, rr *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookie {
+			return c
+		}
+	}
+	t.Fatalf("Expected a session cookie, got %v", rr.Result().Cookies())
+	return nil
+}
+
+func TestHashPassword(t *testing.T) {
+	withTempAccounts(t)
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcrypt.MinCost || strings.Contains(hash, "correct horse") {
+		t.Errorf("Expected a bcrypt hash, got %q", hash)
+	}
+	if !verifyPassword("correct horse", hash) || verifyPassword("correct horsf", hash) {
+		t.Errorf("Expected only the right password to verify")
+	}
+	if other, _ := hashPassword("correct horse"); other == hash {
+		t.Errorf("Expected a random salt for each hash")
+	}
+
+	// Hashes keep their work factor, so raising it does not lock users out
+	passwordCost = bcrypt.MinCost + 1
+	if !verifyPassword("correct horse", hash) {
+		t.Errorf("Expected an older hash to verify after raising the work factor")
+	}
+
+	for _, bad := range []string{"", "plain", "$2a$04$short", "pbkdf2-sha256$1$c2FsdA$aGFzaA"} {
+		if verifyPassword("", bad) {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestAccountStoreCreatedOnRegister(t *testing.T) {
+	path := withTempAccounts(t)
+	if user := accounts.User("some-session"); user != "" {
+		t.Errorf("Expected no user, got %q", user)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected reading not to create the database, got %v", err)
+	}
+	if _, err := accounts.Register("alice", "s3cret-pass"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the database to be created, got %v", err)
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	valid := map[string]string{"Alice": "alice", " bob_99 ": "bob_99", "j.doe-2": "j.doe-2"}
+	for input, expected := range valid {
+		if got, err := normalizeUsername(input); err != nil || got != expected {
+			t.Errorf("%q: expected %q, got %q (%v)", input, expected, got, err)
+		}
+	}
+	for _, input := range []string{"", "ab", "a b c", "alice!", "élodie", strings.Repeat("x", 33)} {
+		if _, err := normalizeUsername(input); err != errInvalidUsername {
+			t.Errorf("%q: expected errInvalidUsername, got %v", input, err)
+		}
+	}
+}
+
+func TestAccountFlow(t *testing.T) {
+	path := withTempAccounts(t)
+	withTempFavorites(t)
+
+	// Favorites marked before registering move to the account
+	anonymous := newBrowser(t)
+	postFavorite(anonymous, "3", "add", "")
+
+	rr := getWithCookie(RegisterHandler, "/register", anonymous)
+	if !strings.Contains(rr.Body.String(), `action="/register"`) || !strings.Contains(rr.Body.String(), csrfToken(anonymous.Value)) {
+		t.Fatalf("Expected the registration form with the CSRF token")
+	}
+
+	rr = postForm(RegisterHandler, "/register", anonymous, url.Values{"username": {"Alice"}, "password": {"s3cret-pass"}, "confirm": {"s3cret-pass"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/favorites" {
+		t.Fatalf("Expected a redirect to the favorites, got %v %q", rr.Code, rr.Header().Get("Location"))
+	}
+	alice := sessionFrom(t, rr)
+	if alice.Value == anonymous.Value {
+		t.Errorf("Expected a new session ID on login")
+	}
+	if got := accounts.User(anonymous.Value); got != "" {
+		t.Errorf("Expected the old session not to be logged in, got %q", got)
+	}
+	if ids := favorites.List("user:alice"); len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("Expected the anonymous favorites on the account, got %v", ids)
+	}
+
+	rr = getWithCookie(FavoritesHandler, "/favorites", alice)
+	if body := rr.Body.String(); !strings.Contains(body, "alice") || !strings.Contains(body, `action="/logout"`) || !strings.Contains(body, `href="/artist/3"`) {
+		t.Errorf("Expected the favorites page of alice with a logout form")
+	}
+	postFavorite(alice, "5", "add", "")
+	if ids := favorites.List("user:alice"); len(ids) != 2 {
+		t.Errorf("Expected favorites to be saved on the account, got %v", ids)
+	}
+
+	// The database keeps neither the password nor usable session IDs
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "s3cret-pass") || strings.Contains(string(data), alice.Value) {
+		t.Errorf("Expected no password or session ID in the database")
+	}
+
+	rr = postForm(LogoutHandler, "/logout", alice, url.Values{})
+	if rr.Code != http.StatusSeeOther || accounts.User(alice.Value) != "" {
+		t.Fatalf("Expected logging out to end the login, got %v", rr.Code)
+	}
+	loggedOut := sessionFrom(t, rr)
+	rr = getWithCookie(GetFavoritesHandler, "/api/v1/favorites", loggedOut)
+	var artists []struct{ Name string }
+	decodeAPIResponse(t, rr, &artists)
+	if len(artists) != 0 {
+		t.Errorf("Expected no favorites after logging out, got %+v", artists)
+	}
+
+	// Logging in again, from a store reopened from the database, finds the favorites
+	accounts.Close()
+	accounts = newAccountStore(path)
+	rr = postForm(LoginHandler, "/login", loggedOut, url.Values{"username": {"alice"}, "password": {"wrong-pass"}})
+	if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Invalid username or password") {
+		t.Errorf("Expected a wrong password to be refused, got %v", rr.Code)
+	}
+	rr = postForm(LoginHandler, "/login", loggedOut, url.Values{"username": {"ALICE"}, "password": {"s3cret-pass"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected a successful login, got %v", rr.Code)
+	}
+	rr = getWithCookie(GetFavoritesHandler, "/api/v1/favorites", sessionFrom(t, rr))
+	decodeAPIResponse(t, rr, &artists)
+	if len(artists) != 2 || artists[0].Name != "Phil Collins" {
+		t.Errorf("Expected the favorites of the account, got %+v", artists)
+	}
+}
+
+func TestAccountErrors(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	session := newBrowser(t)
+	accounts.Register("alice", "s3cret-pass")
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+		form    url.Values
+		status  int
+		message string
+	}{
+		{RegisterHandler, "/register", url.Values{"username": {"Alice"}, "password": {"another-pass"}, "confirm": {"another-pass"}}, http.StatusConflict, "already taken"},
+		{RegisterHandler, "/register", url.Values{"username": {"bob"}, "password": {"short"}, "confirm": {"short"}}, http.StatusBadRequest, "Passwords are 8 to 72"},
+		{RegisterHandler, "/register", url.Values{"username": {"bob"}, "password": {strings.Repeat("x", 73)}, "confirm": {strings.Repeat("x", 73)}}, http.StatusBadRequest, "Passwords are 8 to 72"},
+		{RegisterHandler, "/register", url.Values{"username": {"b"}, "password": {"long enough"}, "confirm": {"long enough"}}, http.StatusBadRequest, "Usernames are 3 to 32"},
+		{RegisterHandler, "/register", url.Values{"username": {"bob"}, "password": {"long enough"}, "confirm": {"long enougH"}}, http.StatusBadRequest, "do not match"},
+		{LoginHandler, "/login", url.Values{"username": {"nobody"}, "password": {"s3cret-pass"}}, http.StatusUnauthorized, "Invalid username or password"},
+	}
+	for _, tt := range tests {
+		rr := postForm(tt.handler, tt.target, session, tt.form)
+		if rr.Code != tt.status || !strings.Contains(rr.Body.String(), tt.message) {
+			t.Errorf("%s %v: expected %v %q, got %v", tt.target, tt.form, tt.status, tt.message, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "s3cret-pass") || strings.Contains(rr.Body.String(), "long enough") {
+			t.Errorf("%s: expected the password not to be echoed back", tt.target)
+		}
+	}
+
+	// Forms without the CSRF token of the session are refused
+	form := url.Values{"username": {"alice"}, "password": {"s3cret-pass"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	LoginHandler(rr, req)
+	if !strings.Contains(rr.Body.String(), expiredFormMessage) || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected a login without a CSRF token to be refused")
+	}
+
+	rr = httptest.NewRecorder()
+	LogoutHandler(rr, httptest.NewRequest("GET", "/logout", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed; got %v", rr.Code)
+	}
+}
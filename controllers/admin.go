@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// adminTokenEnv names the environment variable holding the token of the /admin routes
+const adminTokenEnv = "GROUPIE_ADMIN_TOKEN"
+
+// requireAdmin guards an /admin route. When GROUPIE_ADMIN_TOKEN is set, requests must
+// send it as a bearer token; otherwise only requests from the loopback address are served.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv(adminTokenEnv)
+		if token == "" {
+			if !fromLoopback(r) {
+				writeJSONError(w, http.StatusForbidden, "admin routes are only served to localhost")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSONError(w, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusForbidden, "invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// fromLoopback reports whether a request comes from the local machine
+func fromLoopback(r *http.Request) bool {
+	ip := net.ParseIP(clientIP(r))
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveAdmin(remoteAddr, auth string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	req.RemoteAddr = remoteAddr
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rr := httptest.NewRecorder()
+	requireAdmin(IntegrityHandler)(rr, req)
+	return rr
+}
+
+func TestRequireAdminWithoutToken(t *testing.T) {
+	t.Setenv(adminTokenEnv, "")
+
+	tests := []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"127.0.0.1:40000", http.StatusOK},
+		{"[::1]:40000", http.StatusOK},
+		{"203.0.113.7:40000", http.StatusForbidden},
+		{"192.168.1.10:40000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rr := serveAdmin(tt.remoteAddr, ""); rr.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.remoteAddr, tt.expected, rr.Code)
+		}
+	}
+}
+
+func TestRequireAdminWithToken(t *testing.T) {
+	t.Setenv(adminTokenEnv, "s3cret")
+
+	tests := []struct {
+		name     string
+		auth     string
+		expected int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"wrong", "Bearer guess", http.StatusForbidden},
+		{"valid", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		// The token is required from localhost too
+		rr := serveAdmin("127.0.0.1:40000", tt.auth)
+		if rr.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, rr.Code)
+		}
+		if tt.expected == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate header", tt.name)
+		}
+	}
+}
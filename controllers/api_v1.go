@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+const (
+	apiPrefix      = "/api/v1"
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// APIResponse is the envelope wrapping every successful /api/v1 response
+type APIResponse struct {
+	Data interface{} `json:"data"`
+	Meta *APIMeta    `json:"meta,omitempty"`
+}
+
+// APIMeta describes the page of a paginated list
+type APIMeta struct {
+	Total   int `json:"total"`
+	Page    int `json:"page"`
+	PerPage int `json:"perPage"`
+}
+
+// APIError is the envelope of every /api/v1 error response
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+type APIErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// ArtistDetail is an artist together with all of its concerts and tour statistics
+type ArtistDetail struct {
+	api.Artist
+	Concerts  []Concert `json:"concerts"`
+	TourStats TourStats `json:"tourStats"`
+}
+
+// ArtistFilter restricts the artists list. Zero values disable a restriction.
+type ArtistFilter struct {
+	Query          string
+	Location       string
+	MinMembers     int
+	MaxMembers     int
+	CreatedFrom    int
+	CreatedTo      int
+	FirstAlbumFrom int
+	FirstAlbumTo   int
+}
+
+// writeJSON writes data wrapped in the API envelope
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}, meta *APIMeta) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(APIResponse{Data: data, Meta: meta}); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// writeJSONError writes a JSON error body, the API counterpart of ErrorHandler
+func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(APIError{APIErrorBody{Status: statusCode, Message: message}}); err != nil {
+		log.Printf("Error encoding JSON error: %v", err)
+	}
+}
+
+// allowGet rejects anything but GET and HEAD requests with a JSON error
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// APINotFoundHandler answers unknown /api/ routes with a JSON 404
+func APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no API route for %s", r.URL.Path))
+}
+
+// parseIntParam parses an optional integer query parameter
+func parseIntParam(values url.Values, name string) (int, error) {
+	s := strings.TrimSpace(values.Get(name))
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+// parsePage reads the page and per_page parameters
+func parsePage(values url.Values) (int, int, error) {
+	page, err := parseIntParam(values, "page")
+	if err != nil {
+		return 0, 0, err
+	}
+	perPage, err := parseIntParam(values, "per_page")
+	if err != nil {
+		return 0, 0, err
+	}
+	if page == 0 {
+		page = 1
+	}
+	if perPage == 0 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		return 0, 0, fmt.Errorf("per_page must not exceed %d", maxPerPage)
+	}
+	return page, perPage, nil
+}
+
+// paginate returns the bounds of the requested page within a list of total items.
+// Pages past the end are empty; they are detected before multiplying so huge page
+// numbers cannot overflow.
+func paginate(total, page, perPage int) (int, int) {
+	if page-1 > total/perPage {
+		return total, total
+	}
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// parseArtistFilter builds an artist filter from the query parameters. The search
+// text may be given as q or, like the HTML page, as query.
+func parseArtistFilter(values url.Values) (ArtistFilter, error) {
+	f := ArtistFilter{Query: values.Get("q"), Location: values.Get("location")}
+	if f.Query == "" {
+		f.Query = values.Get("query")
+	}
+
+	ints := []struct {
+		name string
+		dest *int
+	}{
+		{"members_min", &f.MinMembers},
+		{"members_max", &f.MaxMembers},
+		{"created_from", &f.CreatedFrom},
+		{"created_to", &f.CreatedTo},
+		{"first_album_from", &f.FirstAlbumFrom},
+		{"first_album_to", &f.FirstAlbumTo},
+	}
+	for _, p := range ints {
+		n, err := parseIntParam(values, p.name)
+		if err != nil {
+			return f, err
+		}
+		*p.dest = n
+	}
+	return f, nil
+}
+
+// firstAlbumYear returns the year of a DD-MM-YYYY first album date, or 0
+func firstAlbumYear(a api.Artist) int {
+	if len(a.FirstAlbum) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(a.FirstAlbum[len(a.FirstAlbum)-4:])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// Matches reports whether the artist satisfies the filter's non-text criteria.
+// The artist's Locations field must hold location names, see withLocationNames.
+func (f ArtistFilter) Matches(a api.Artist) bool {
+	members := len(a.Members)
+	year := firstAlbumYear(a)
+	switch {
+	case f.MinMembers > 0 && members < f.MinMembers,
+		f.MaxMembers > 0 && members > f.MaxMembers,
+		f.CreatedFrom > 0 && a.CreationDate < f.CreatedFrom,
+		f.CreatedTo > 0 && a.CreationDate > f.CreatedTo,
+		f.FirstAlbumFrom > 0 && year < f.FirstAlbumFrom,
+		f.FirstAlbumTo > 0 && year > f.FirstAlbumTo:
+		return false
+	}
+
+	if f.Location != "" {
+		place := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(f.Location), "_", " "))
+		for _, loc := range strings.Split(a.Locations, ", ") {
+			city, country := splitLocation(loc)
+			if strings.Contains(city, place) || strings.Contains(country, place) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// applyArtistFilter searches the artists and keeps those matching the filter
+func applyArtistFilter(artists []api.Artist, relations []api.Relation, f ArtistFilter) []ArtistResult {
+	results := []ArtistResult{}
+	for _, result := range searchArtists(artists, relations, f.Query) {
+		if f.Matches(result.Artist) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// GetArtistsHandler handles the /api/v1/artists route, a paginated and filterable list
+func GetArtistsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	filter, err := parseArtistFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, perPage, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, locations, _, relations := getCachedData()
+	if artists == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "artist data is not available yet, please try again later")
+		return
+	}
+
+	results := applyArtistFilter(withLocationNames(artists, locations), relations, filter)
+	start, end := paginate(len(results), page, perPage)
+	writeJSON(w, http.StatusOK, results[start:end], &APIMeta{Total: len(results), Page: page, PerPage: perPage})
+}
+
+// GetArtistByIDHandler handles the /api/v1/artists/{id} route
+func GetArtistByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+
+	// Get artist ID from the URL path
+	idStr := strings.TrimPrefix(r.URL.Path, apiPrefix+"/artists/")
+	artistID, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid artist ID")
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	artist, ok := findArtist(artists, artistID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "artist not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ArtistDetail{Artist: artist, Concerts: artistConcerts(relations, artistID), TourStats: getTourStats(artistID)}, nil)
+}
+
+// findArtist looks an artist up by ID
+func findArtist(artists []api.Artist, id int) (api.Artist, bool) {
+	for _, a := range artists {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return api.Artist{}, false
+}
+
+// artistConcerts returns the artist's concerts in chronological order
+func artistConcerts(relations []api.Relation, id int) []Concert {
+	for _, rel := range relations {
+		if rel.ID == id {
+			if concerts := buildConcerts([]api.Relation{rel}); concerts != nil {
+				return concerts
+			}
+			break
+		}
+	}
+	return []Concert{}
+}
+
+// GetLocationsHandler handles the /api/v1/locations route
+func GetLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	_, locations, _, _ := getCachedData()
+	if locations == nil {
+		locations = []api.Location{}
+	}
+	writeJSON(w, http.StatusOK, locations, nil)
+}
+
+// GetDatesHandler handles the /api/v1/dates route
+func GetDatesHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	_, _, dates, _ := getCachedData()
+	if dates == nil {
+		dates = []api.Date{}
+	}
+	writeJSON(w, http.StatusOK, dates, nil)
+}
+
+// GetRelationsHandler handles the /api/v1/relations route
+func GetRelationsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	_, _, _, relations := getCachedData()
+	if relations == nil {
+		relations = []api.Relation{}
+	}
+	writeJSON(w, http.StatusOK, relations, nil)
+}
+
+// SearchHandler handles the /api/v1/search route, returning every artist matching q
+// along with the reason it matched
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "the q parameter is required")
+		return
+	}
+	page, perPage, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, locations, _, relations := getCachedData()
+	results := searchArtists(withLocationNames(artists, locations), relations, query)
+	if results == nil {
+		results = []ArtistResult{}
+	}
+	start, end := paginate(len(results), page, perPage)
+	writeJSON(w, http.StatusOK, results[start:end], &APIMeta{Total: len(results), Page: page, PerPage: perPage})
+}
+
+// SuggestionsHandler handles the /api/v1/suggestions route
+func SuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	artists, locations, _, _ := getCachedData()
+	suggestions := searchSuggestions(withLocationNames(artists, locations), strings.TrimSpace(r.URL.Query().Get("q")))
+	writeJSON(w, http.StatusOK, suggestions, nil)
+}
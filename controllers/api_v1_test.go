@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// decodeAPIResponse decodes an /api/v1 envelope, storing its data into data
+func decodeAPIResponse(t *testing.T, rr *httptest.ResponseRecorder, data interface{}) *APIMeta {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected a JSON response, got %q", ct)
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+		Meta *APIMeta        `json:"meta"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		t.Fatalf("Could not decode response data: %v", err)
+	}
+	return envelope.Meta
+}
+
+// decodeAPIError decodes an /api/v1 error body
+func decodeAPIError(t *testing.T, rr *httptest.ResponseRecorder) APIErrorBody {
+	t.Helper()
+	var body APIError
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Could not decode error: %v", err)
+	}
+	if body.Error.Status != rr.Code || body.Error.Message == "" {
+		t.Errorf("Expected error body to repeat status %d with a message, got %+v", rr.Code, body.Error)
+	}
+	return body.Error
+}
+
+func serveAPI(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(method, target, nil))
+	return rr
+}
+
+func TestAPIArtistsPagination(t *testing.T) {
+	rr := serveAPI(GetArtistsHandler, "GET", "/api/v1/artists?page=2&per_page=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+
+	var artists []ArtistResult
+	meta := decodeAPIResponse(t, rr, &artists)
+	if meta == nil || meta.Total != len(fixtureArtists) || meta.Page != 2 || meta.PerPage != 2 {
+		t.Errorf("Expected page 2 of %d artists, got %+v", len(fixtureArtists), meta)
+	}
+	if len(artists) != 2 || artists[0].ID != 3 {
+		t.Errorf("Expected artists 3 and 4, got %+v", artists)
+	}
+
+	// Pages past the end are empty, even when the offset would overflow
+	pastEnd := []struct {
+		handler http.HandlerFunc
+		target  string
+	}{
+		{GetArtistsHandler, "/api/v1/artists?page=9&per_page=2"},
+		{SearchHandler, "/api/v1/search?q=a&page=9223372036854775807&per_page=2"},
+	}
+	for _, tt := range pastEnd {
+		rr := serveAPI(tt.handler, "GET", tt.target)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status OK; got %v", tt.target, rr.Code)
+		}
+		var results []ArtistResult
+		decodeAPIResponse(t, rr, &results)
+		if len(results) != 0 {
+			t.Errorf("%s: expected an empty page, got %+v", tt.target, results)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		total, page, perPage int
+		start, end           int
+	}{
+		{5, 1, 2, 0, 2},
+		{5, 3, 2, 4, 5},
+		{5, 4, 2, 5, 5},
+		{0, 1, 20, 0, 0},
+		{5, math.MaxInt, 2, 5, 5},
+		{5, math.MaxInt, 100, 5, 5},
+	}
+	for _, tt := range tests {
+		start, end := paginate(tt.total, tt.page, tt.perPage)
+		if start != tt.start || end != tt.end {
+			t.Errorf("paginate(%d, %d, %d): expected [%d:%d], got [%d:%d]", tt.total, tt.page, tt.perPage, tt.start, tt.end, start, end)
+		}
+	}
+}
+
+func TestAPIArtistsFilters(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"members_max=1", []int{5}},
+		{"created_from=1966&created_to=1975", []int{1, 4, 5}},
+		{"first_album_from=1980", []int{2, 5}},
+		{"location=japan", []int{1}},
+		{"q=collins&members_min=2", []int{4}},
+	}
+	for _, tt := range tests {
+		rr := serveAPI(GetArtistsHandler, "GET", "/api/v1/artists?"+tt.query)
+		var artists []ArtistResult
+		decodeAPIResponse(t, rr, &artists)
+
+		var ids []int
+		for _, a := range artists {
+			ids = append(ids, a.ID)
+		}
+		if len(ids) != len(tt.expected) {
+			t.Errorf("%s: expected artists %v, got %v", tt.query, tt.expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.expected[i] {
+				t.Errorf("%s: expected artists %v, got %v", tt.query, tt.expected, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestAPIArtistsInvalidParameter(t *testing.T) {
+	rr := serveAPI(GetArtistsHandler, "GET", "/api/v1/artists?created_from=soon")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
+
+func TestAPIArtistByIDEmbedsConcerts(t *testing.T) {
+	rr := serveAPI(GetArtistByIDHandler, "GET", "/api/v1/artists/4")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+
+	var artist ArtistDetail
+	decodeAPIResponse(t, rr, &artist)
+	if artist.Name != "Genesis" || len(artist.Concerts) != 3 {
+		t.Errorf("Expected Genesis with 3 concerts, got %+v", artist)
+	}
+	if artist.Concerts[0].Date != "2019-08-20" || artist.Concerts[0].Location != "los_angeles-usa" {
+		t.Errorf("Expected concerts in chronological order, got %+v", artist.Concerts[0])
+	}
+}
+
+func TestAPIArtistByIDErrors(t *testing.T) {
+	rr := serveAPI(GetArtistByIDHandler, "GET", "/api/v1/artists/999")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+
+	rr = serveAPI(GetArtistByIDHandler, "GET", "/api/v1/artists/queen")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	rr := serveAPI(GetLocationsHandler, "POST", "/api/v1/locations")
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
+
+func TestAPINotFoundHandler(t *testing.T) {
+	rr := serveAPI(APINotFoundHandler, "GET", "/api/v1/nothing")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
+
+func TestAPISearch(t *testing.T) {
+	rr := serveAPI(SearchHandler, "GET", "/api/v1/search?q=gilmour")
+	var results []ArtistResult
+	decodeAPIResponse(t, rr, &results)
+	if len(results) != 1 || results[0].Match == nil || results[0].Match.Field != "member" {
+		t.Errorf("Expected Pink Floyd matched on a member, got %+v", results)
+	}
+
+	rr = serveAPI(SearchHandler, "GET", "/api/v1/search")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request without q; got %v", rr.Code)
+	}
+}
+
+func TestAPISuggestions(t *testing.T) {
+	rr := serveAPI(SuggestionsHandler, "GET", "/api/v1/suggestions?q=osaka")
+	var suggestions []Suggestion
+	decodeAPIResponse(t, rr, &suggestions)
+	if len(suggestions) != 1 || suggestions[0] != (Suggestion{"osaka-japan", "location"}) {
+		t.Errorf("Expected the Osaka location to be suggested, got %+v", suggestions)
+	}
+}
+
+func TestAPIConcerts(t *testing.T) {
+	rr := serveAPI(SearchConcertsHandler, "GET", "/api/v1/concerts?location=kenya")
+	var results []ArtistConcerts
+	decodeAPIResponse(t, rr, &results)
+	if len(results) != 2 || results[0].Artist.Name != "SOJA" {
+		t.Errorf("Expected SOJA and Phil Collins to have played Kenya, got %+v", results)
+	}
+
+	rr = serveAPI(SearchConcertsHandler, "GET", "/api/v1/concerts?from=yesterday")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+var (
+	artistCache        []api.Artist
+	locationCache      []api.Location
+	dateCache          []api.Date
+	relationCache      []api.Relation
+	integrityReport    IntegrityReport
+	geocodingReport    GeocodingReport
+	tourStatsCache     map[int]TourStats
+	catalogStats       CatalogStats
+	similarCache       map[int][]SimilarArtist
+	coBillCache        []CoBill
+	memberCache        []Member
+	changeLog          []Change
+	cacheTime          time.Time
+	cacheMutex         sync.RWMutex
+	isCacheInitialized bool
+
+	// refreshMutex makes sure only one refresh talks to the API at a time
+	refreshMutex sync.Mutex
+)
+
+const cacheDuration = 10 * time.Minute
+
+func initCache() {
+	if cacheInitialized() {
+		return
+	}
+
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+
+	if !cacheInitialized() {
+		updateCache()
+	}
+}
+
+func cacheInitialized() bool {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return isCacheInitialized
+}
+
+// refreshCache updates the cache in the background unless a refresh is already running
+func refreshCache() {
+	if !refreshMutex.TryLock() {
+		return
+	}
+	defer refreshMutex.Unlock()
+	updateCache()
+}
+
+// updateCache fetches every dataset from the API. Datasets that fail to load keep
+// their previously cached value and fail the integrity report.
+func updateCache() {
+	cacheMutex.RLock()
+	artists, locations, dates, relations := artistCache, locationCache, dateCache, relationCache
+	cacheMutex.RUnlock()
+
+	// Each fetch records its error in its own slot
+	var errs [4]error
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+		fetched, err := api.GetArtists()
+		if err != nil {
+			log.Printf("Error refreshing artists: %v", err)
+			errs[0] = fmt.Errorf("artists: %v", err)
+			return
+		}
+		artists = fetched
+	}()
+
+	go func() {
+		defer wg.Done()
+		fetched, err := api.GetLocations()
+		if err != nil {
+			log.Printf("Error refreshing locations: %v", err)
+			errs[1] = fmt.Errorf("locations: %v", err)
+			return
+		}
+		locations = fetched
+	}()
+
+	go func() {
+		defer wg.Done()
+		fetched, err := api.GetDates()
+		if err != nil {
+			log.Printf("Error refreshing dates: %v", err)
+			errs[2] = fmt.Errorf("dates: %v", err)
+			return
+		}
+		dates = fetched
+	}()
+
+	go func() {
+		defer wg.Done()
+		fetched, err := api.GetRelations()
+		if err != nil {
+			log.Printf("Error refreshing relations: %v", err)
+			errs[3] = fmt.Errorf("relations: %v", err)
+			return
+		}
+		relations = fetched
+	}()
+
+	wg.Wait()
+	storeCache(artists, locations, dates, relations)
+	recordFetchErrors(errs[:])
+}
+
+// storeCache replaces the cached datasets and recomputes the data derived from them
+func storeCache(artists []api.Artist, locations []api.Location, dates []api.Date, relations []api.Relation) {
+	report := checkIntegrity(artists, locations, dates, relations)
+	report.Log()
+	geoReport := geocodeLocations(locations)
+	geoReport.Log()
+	tourStats := buildAllTourStats(relations)
+	stats := buildCatalogStats(artists, relations, time.Now())
+	similar := buildSimilarArtists(artists, relations)
+	coBills := buildCoBills(artists, relations)
+	members := buildMembers(artists)
+
+	cacheMutex.Lock()
+
+	// The first load is the baseline; later loads are diffed against the previous one
+	var changes []Change
+	if artistCache != nil {
+		changes = detectChanges(artistCache, relationCache, artists, relations, time.Now())
+		if len(changes) > 0 {
+			log.Printf("Detected %d new artists and concerts", len(changes))
+		}
+		changeLog = recordChanges(changeLog, changes)
+	}
+
+	artistCache = artists
+	locationCache = locations
+	dateCache = dates
+	relationCache = relations
+	integrityReport = report
+	geocodingReport = geoReport
+	tourStatsCache = tourStats
+	catalogStats = stats
+	similarCache = similar
+	coBillCache = coBills
+	memberCache = members
+	cacheTime = time.Now()
+	isCacheInitialized = true
+	cacheMutex.Unlock()
+
+	// Notifications are saved to a file and posted to webhooks, so they are sent
+	// in the background once the cache is released
+	notifications.Queue(changes, locations, relations)
+}
+
+func getCachedData() ([]api.Artist, []api.Location, []api.Date, []api.Relation) {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+
+	if time.Since(cacheTime) > cacheDuration {
+		go refreshCache()
+	}
+
+	return artistCache, locationCache, dateCache, relationCache
+}
+
+// cachedAt returns the time the cached data was last stored
+func cachedAt() time.Time {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return cacheTime
+}
+
+// withLocationNames returns a copy of the artists whose Locations field holds the
+// comma separated names of their concert locations instead of the API URL.
+// Locations are joined on the artist ID; artists without a location record are
+// left unchanged.
+func withLocationNames(artists []api.Artist, locations []api.Location) []api.Artist {
+	byID := make(map[int][]string, len(locations))
+	for _, l := range locations {
+		byID[l.ID] = l.Locations
+	}
+
+	enriched := make([]api.Artist, len(artists))
+	for i, a := range artists {
+		if names, ok := byID[a.ID]; ok {
+			a.Locations = strings.Join(names, ", ")
+		}
+		enriched[i] = a
+	}
+	return enriched
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithLocationNamesJoinsOnID(t *testing.T) {
+	enriched := withLocationNames(fixtureArtists, fixtureLocations)
+	for _, a := range enriched {
+		if a.ID == 1 && !strings.Contains(a.Locations, "osaka-japan") {
+			t.Errorf("Expected Queen to be enriched with its own locations, got %q", a.Locations)
+		}
+		if a.ID == 2 && strings.Contains(a.Locations, "osaka-japan") {
+			t.Errorf("Expected SOJA not to get Queen's locations, got %q", a.Locations)
+		}
+	}
+
+	if strings.Contains(fixtureArtists[0].Locations, "osaka-japan") {
+		t.Errorf("Expected the cached artists not to be modified")
+	}
+}
+
+func TestServeArtistsSearchesJoinedLocations(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?query=playa", nil)
+	rr := httptest.NewRecorder()
+	ServeArtists(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "SOJA") || strings.Contains(body, ">Queen<") {
+		t.Errorf("Expected only SOJA to match a concert in Playa del Carmen")
+	}
+	if !strings.Contains(body, "matched: location") {
+		t.Errorf("Expected the card to explain the location match")
+	}
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// maxChanges is the number of changes kept for the feed, newest first
+const maxChanges = 200
+
+// Change is an artist or a concert that appeared in the data on a cache refresh.
+// The change log is kept in memory only and starts empty on every start.
+type Change struct {
+	Kind       string     `json:"kind"` // "artist" or "concert"
+	Artist     api.Artist `json:"artist"`
+	Concert    *Concert   `json:"concert,omitempty"`
+	DetectedAt time.Time  `json:"detectedAt"`
+}
+
+// ID identifies the change in the Atom feed
+func (c Change) ID() string {
+	if c.Concert != nil {
+		return "urn:groupie-tracker:concert:" + icsUID(*c.Concert)
+	}
+	return fmt.Sprintf("urn:groupie-tracker:artist:%d", c.Artist.ID)
+}
+
+// Title describes the change in one line. Concerts dated before the day of the
+// refresh that detected them are in the past tense.
+func (c Change) Title() string {
+	if c.Concert != nil {
+		verb := "will play"
+		if c.Concert.Time.Before(c.DetectedAt.Truncate(24 * time.Hour)) {
+			verb = "played"
+		}
+		return fmt.Sprintf("%s %s %s on %s", c.Artist.Name, verb, formatLocation(c.Concert.Location), c.Concert.Time.Format("2 January 2006"))
+	}
+	return fmt.Sprintf("New artist: %s", c.Artist.Name)
+}
+
+// detectChanges lists the artists and concerts of the new data that were not in the
+// previous data. Concerts of new artists are not listed separately.
+func detectChanges(prevArtists []api.Artist, prevRelations []api.Relation, artists []api.Artist, relations []api.Relation, now time.Time) []Change {
+	known := make(map[int]bool, len(prevArtists))
+	for _, a := range prevArtists {
+		known[a.ID] = true
+	}
+	knownConcerts := map[string]bool{}
+	for _, c := range buildConcerts(prevRelations) {
+		knownConcerts[icsUID(c)] = true
+	}
+
+	byID := make(map[int]api.Artist, len(artists))
+	var changes []Change
+	for _, a := range artists {
+		byID[a.ID] = a
+		if !known[a.ID] {
+			changes = append(changes, Change{Kind: "artist", Artist: a, DetectedAt: now})
+		}
+	}
+	for _, c := range buildConcerts(relations) {
+		artist, ok := byID[c.ArtistID]
+		if !ok || !known[c.ArtistID] || knownConcerts[icsUID(c)] {
+			continue
+		}
+		concert := c
+		changes = append(changes, Change{Kind: "concert", Artist: artist, Concert: &concert, DetectedAt: now})
+	}
+	return changes
+}
+
+// recordChanges adds the changes of a refresh to the front of the log, keeping at
+// most maxChanges
+func recordChanges(existing, changes []Change) []Change {
+	updated := append(append([]Change{}, changes...), existing...)
+	if len(updated) > maxChanges {
+		updated = updated[:maxChanges]
+	}
+	return updated
+}
+
+// getChanges returns the change log and the time of the last refresh
+func getChanges() ([]Change, time.Time) {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return changeLog, cacheTime
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// baseURL returns the scheme and host the request was made to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// buildChangesFeed renders the changes as an Atom feed
+func buildChangesFeed(changes []Change, updated time.Time, base string) atomFeed {
+	if len(changes) > 0 {
+		updated = changes[0].DetectedAt
+	}
+	feed := atomFeed{
+		ID:      base + "/feeds/changes.atom",
+		Title:   "Groupie Tracker: new artists and concerts",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "Groupie Tracker"},
+		Links: []atomLink{
+			{Href: base + "/feeds/changes.atom", Rel: "self"},
+			{Href: base + "/"},
+		},
+	}
+	for _, c := range changes {
+		summary := fmt.Sprintf("%s was formed in %d and released its first album on %s.", c.Artist.Name, c.Artist.CreationDate, c.Artist.FirstAlbum)
+		if c.Concert != nil {
+			summary = fmt.Sprintf("New concert date for %s: %s in %s.", c.Artist.Name, c.Concert.Date, formatLocation(c.Concert.Location))
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      c.ID(),
+			Title:   c.Title(),
+			Updated: c.DetectedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: fmt.Sprintf("%s/artist/%d", base, c.Artist.ID)},
+			Summary: summary,
+		})
+	}
+	return feed
+}
+
+// ChangesFeedHandler handles the /feeds/changes.atom route
+func ChangesFeedHandler(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	changes, updated := getChanges()
+	feed := buildChangesFeed(changes, updated, baseURL(r))
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("Error encoding changes feed: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// withNewData returns the fixtures plus a new artist and a new concert of Queen
+func withNewData() ([]api.Artist, []api.Relation) {
+	artists := append(append([]api.Artist{}, fixtureArtists...), fixtureArtist(6, "Gorillaz", 1998, "26-03-2001", "Damon Albarn"))
+
+	relations := append([]api.Relation{}, fixtureRelations...)
+	queen := api.Relation{ID: 1, DatesLocations: map[string][]string{"tokyo-japan": {"01-02-2020"}}}
+	for location, dates := range fixtureRelations[0].DatesLocations {
+		queen.DatesLocations[location] = dates
+	}
+	relations[0] = queen
+	relations = append(relations, api.Relation{ID: 6, DatesLocations: map[string][]string{"london-uk": {"01-06-2021"}}})
+	return artists, relations
+}
+
+func TestDetectChanges(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if changes := detectChanges(fixtureArtists, fixtureRelations, fixtureArtists, fixtureRelations, now); len(changes) != 0 {
+		t.Errorf("Expected no changes between identical data, got %+v", changes)
+	}
+
+	artists, relations := withNewData()
+	changes := detectChanges(fixtureArtists, fixtureRelations, artists, relations, now)
+	if len(changes) != 2 {
+		t.Fatalf("Expected a new artist and a new concert, got %+v", changes)
+	}
+	if changes[0].Kind != "artist" || changes[0].Artist.Name != "Gorillaz" {
+		t.Errorf("Expected Gorillaz to be new, got %+v", changes[0])
+	}
+	if changes[1].Kind != "concert" || changes[1].Concert.Location != "tokyo-japan" || changes[1].Artist.Name != "Queen" {
+		t.Errorf("Expected the Tokyo concert of Queen to be new, got %+v", changes[1])
+	}
+	if changes[1].Title() != "Queen played Tokyo, Japan on 1 February 2020" {
+		t.Errorf("Unexpected title %q", changes[1].Title())
+	}
+}
+
+func TestChangeTitleTense(t *testing.T) {
+	concert := Concert{Location: "tokyo-japan", Time: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		detected time.Time
+		expected string
+	}{
+		{time.Date(2019, 12, 1, 8, 0, 0, 0, time.UTC), "Queen will play Tokyo, Japan on 1 February 2020"},
+		{time.Date(2020, 2, 1, 18, 0, 0, 0, time.UTC), "Queen will play Tokyo, Japan on 1 February 2020"},
+		{time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC), "Queen played Tokyo, Japan on 1 February 2020"},
+	}
+	for _, tt := range tests {
+		c := Change{Kind: "concert", Artist: fixtureArtists[0], Concert: &concert, DetectedAt: tt.detected}
+		if got := c.Title(); got != tt.expected {
+			t.Errorf("Detected on %s: expected %q, got %q", tt.detected.Format("2006-01-02"), tt.expected, got)
+		}
+	}
+}
+
+func TestRecordChangesKeepsNewestFirst(t *testing.T) {
+	var changes []Change
+	for i := 0; i < maxChanges+10; i++ {
+		changes = recordChanges(changes, []Change{{Kind: "artist", Artist: api.Artist{ID: i}}})
+	}
+	if len(changes) != maxChanges || changes[0].Artist.ID != maxChanges+9 {
+		t.Errorf("Expected the %d newest changes, got %d starting with %d", maxChanges, len(changes), changes[0].Artist.ID)
+	}
+}
+
+func TestChangesFeed(t *testing.T) {
+	withTempSavedSearches(t)
+	defer func() {
+		seedTestCache()
+		cacheMutex.Lock()
+		changeLog = nil
+		cacheMutex.Unlock()
+	}()
+
+	artists, relations := withNewData()
+	storeCache(artists, fixtureLocations, fixtureDates, relations)
+
+	rr := httptest.NewRecorder()
+	ChangesFeedHandler(rr, httptest.NewRequest("GET", "http://example.com/feeds/changes.atom", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Expected an Atom feed, got %q", ct)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Invalid feed: %v", err)
+	}
+	if feed.XMLName.Space != "http://www.w3.org/2005/Atom" || feed.Updated == "" {
+		t.Errorf("Expected an Atom feed with an updated date, got %+v", feed)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", feed.Entries)
+	}
+	if feed.Entries[0].ID != "urn:groupie-tracker:artist:6" || feed.Entries[0].Link.Href != "http://example.com/artist/6" {
+		t.Errorf("Unexpected entry %+v", feed.Entries[0])
+	}
+	if _, err := time.Parse(time.RFC3339, feed.Entries[1].Updated); err != nil {
+		t.Errorf("Expected RFC 3339 dates, got %q", feed.Entries[1].Updated)
+	}
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// CoBillArtist is an artist on a shared bill
+type CoBillArtist struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CoBill is a location and day on which several artists played, such as a festival
+type CoBill struct {
+	Date     string         `json:"date"`
+	Location string         `json:"location"`
+	Name     string         `json:"name"`
+	Artists  []CoBillArtist `json:"artists"`
+}
+
+// CoBillEdge links two artists who shared bills, for the graph exports
+type CoBillEdge struct {
+	Source CoBillArtist
+	Target CoBillArtist
+	Dates  []string
+}
+
+// buildCoBills finds the locations and days shared by several artists, in
+// chronological order. Artists are listed by ID.
+func buildCoBills(artists []api.Artist, relations []api.Relation) []CoBill {
+	names := make(map[int]string, len(artists))
+	for _, a := range artists {
+		names[a.ID] = a.Name
+	}
+
+	type slot struct{ date, location string }
+	bills := map[slot][]CoBillArtist{}
+	var slots []slot
+	for _, c := range buildConcerts(relations) {
+		s := slot{c.Date, c.Location}
+		billed, ok := bills[s]
+		if !ok {
+			slots = append(slots, s)
+		}
+		if !hasCoBillArtist(billed, c.ArtistID) {
+			bills[s] = append(billed, CoBillArtist{ID: c.ArtistID, Name: names[c.ArtistID]})
+		}
+	}
+
+	coBills := []CoBill{}
+	for _, s := range slots {
+		billed := bills[s]
+		if len(billed) < 2 {
+			continue
+		}
+		sort.Slice(billed, func(i, j int) bool { return billed[i].ID < billed[j].ID })
+		coBills = append(coBills, CoBill{Date: s.date, Location: s.location, Name: formatLocation(s.location), Artists: billed})
+	}
+	sort.SliceStable(coBills, func(i, j int) bool {
+		if coBills[i].Date != coBills[j].Date {
+			return coBills[i].Date < coBills[j].Date
+		}
+		return coBills[i].Location < coBills[j].Location
+	})
+	return coBills
+}
+
+// hasCoBillArtist reports whether an artist is on a bill
+func hasCoBillArtist(billed []CoBillArtist, id int) bool {
+	for _, a := range billed {
+		if a.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// artistCoBills returns the shared bills an artist played
+func artistCoBills(coBills []CoBill, id int) []CoBill {
+	matched := []CoBill{}
+	for _, b := range coBills {
+		if hasCoBillArtist(b.Artists, id) {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
+
+// coBillEdges turns shared bills into edges between every pair of artists on them
+func coBillEdges(coBills []CoBill) []CoBillEdge {
+	index := map[[2]int]int{}
+	var edges []CoBillEdge
+	for _, b := range coBills {
+		for i, source := range b.Artists {
+			for _, target := range b.Artists[i+1:] {
+				key := [2]int{source.ID, target.ID}
+				n, ok := index[key]
+				if !ok {
+					n = len(edges)
+					index[key] = n
+					edges = append(edges, CoBillEdge{Source: source, Target: target})
+				}
+				edges[n].Dates = append(edges[n].Dates, b.Date)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Source.ID != edges[j].Source.ID {
+			return edges[i].Source.ID < edges[j].Source.ID
+		}
+		return edges[i].Target.ID < edges[j].Target.ID
+	})
+	return edges
+}
+
+// coBillNodes lists the artists of the edges by ID
+func coBillNodes(edges []CoBillEdge) []CoBillArtist {
+	seen := map[int]bool{}
+	var nodes []CoBillArtist
+	for _, e := range edges {
+		for _, a := range []CoBillArtist{e.Source, e.Target} {
+			if !seen[a.ID] {
+				seen[a.ID] = true
+				nodes = append(nodes, a)
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// dotQuote quotes a string as a DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// coBillDOT renders the co-bill graph in the Graphviz DOT language. Edges are
+// weighted by the number of shared bills.
+func coBillDOT(coBills []CoBill) string {
+	edges := coBillEdges(coBills)
+	var b strings.Builder
+	b.WriteString("graph cobills {\n")
+	for _, n := range coBillNodes(edges) {
+		fmt.Fprintf(&b, "  a%d [label=%s];\n", n.ID, dotQuote(n.Name))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  a%d -- a%d [weight=%d, label=%s];\n", e.Source.ID, e.Target.ID, len(e.Dates), dotQuote(strings.Join(e.Dates, ", ")))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// GraphML types, see http://graphml.graphdrawing.org
+type graphML struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// coBillGraphML describes the co-bill graph in GraphML
+func coBillGraphML(coBills []CoBill) graphML {
+	edges := coBillEdges(coBills)
+	doc := graphML{
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+			{ID: "dates", For: "edge", Name: "dates", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "cobills", EdgeDefault: "undirected"},
+	}
+	for _, n := range coBillNodes(edges) {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: "a" + strconv.Itoa(n.ID), Data: []graphMLData{{Key: "name", Value: n.Name}}})
+	}
+	for _, e := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: "a" + strconv.Itoa(e.Source.ID),
+			Target: "a" + strconv.Itoa(e.Target.ID),
+			Data: []graphMLData{
+				{Key: "weight", Value: strconv.Itoa(len(e.Dates))},
+				{Key: "dates", Value: strings.Join(e.Dates, ", ")},
+			},
+		})
+	}
+	return doc
+}
+
+// getCoBills returns the shared bills found on the last refresh
+func getCoBills() []CoBill {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return coBillCache
+}
+
+// GetCoBillsHandler handles the /api/v1/cobills route. An artist parameter limits
+// the bills to those of one artist.
+func GetCoBillsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	artistID, err := parseIntParam(r.URL.Query(), "artist")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	coBills := getCoBills()
+	if artistID != 0 {
+		coBills = artistCoBills(coBills, artistID)
+	}
+	if coBills == nil {
+		coBills = []CoBill{}
+	}
+	writeJSON(w, http.StatusOK, coBills, nil)
+}
+
+// ExportCoBillsDOTHandler handles the /export/cobills.dot route
+func ExportCoBillsDOTHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowExport(w, r) {
+		return
+	}
+	initCache()
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="cobills.dot"`)
+	w.Write([]byte(coBillDOT(getCoBills())))
+}
+
+// ExportCoBillsGraphMLHandler handles the /export/cobills.graphml route
+func ExportCoBillsGraphMLHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowExport(w, r) {
+		return
+	}
+	initCache()
+	w.Header().Set("Content-Type", "application/graphml+xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="cobills.graphml"`)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(coBillGraphML(getCoBills())); err != nil {
+		log.Printf("Error encoding GraphML: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+func TestBuildCoBills(t *testing.T) {
+	coBills := buildCoBills(fixtureArtists, fixtureRelations)
+	if len(coBills) != 2 {
+		t.Fatalf("Expected 2 shared bills, got %+v", coBills)
+	}
+	if b := coBills[0]; b.Date != "2019-08-20" || b.Location != "los_angeles-usa" || b.Name != "Los Angeles, USA" ||
+		len(b.Artists) != 2 || b.Artists[0].Name != "Queen" || b.Artists[1].Name != "Genesis" {
+		t.Errorf("Expected Queen and Genesis in Los Angeles first, got %+v", b)
+	}
+	if b := coBills[1]; b.Date != "2019-10-12" || b.Artists[0].ID != 4 || b.Artists[1].ID != 5 {
+		t.Errorf("Expected Genesis and Phil Collins in Berlin, got %+v", b)
+	}
+
+	if bills := artistCoBills(coBills, 4); len(bills) != 2 {
+		t.Errorf("Expected Genesis on both bills, got %+v", bills)
+	}
+	if bills := artistCoBills(coBills, 3); len(bills) != 0 || bills == nil {
+		t.Errorf("Expected no bills for Pink Floyd, got %+v", bills)
+	}
+}
+
+func TestBuildCoBillsIgnoresRepeatedDates(t *testing.T) {
+	relations := []api.Relation{{ID: 1, DatesLocations: map[string][]string{"paris-france": {"01-01-2020", "01-01-2020"}}}}
+	if coBills := buildCoBills(fixtureArtists, relations); len(coBills) != 0 {
+		t.Errorf("Expected an artist not to share a bill with itself, got %+v", coBills)
+	}
+}
+
+func TestCoBillDOT(t *testing.T) {
+	coBills := []CoBill{
+		{Date: "2020-01-01", Artists: []CoBillArtist{{1, `The "Band"`}, {2, "Solo"}}},
+		{Date: "2020-02-01", Artists: []CoBillArtist{{1, `The "Band"`}, {2, "Solo"}, {3, "Trio"}}},
+	}
+	dot := coBillDOT(coBills)
+	for _, line := range []string{
+		"graph cobills {",
+		`  a1 [label="The \"Band\""];`,
+		`  a1 -- a2 [weight=2, label="2020-01-01, 2020-02-01"];`,
+		`  a2 -- a3 [weight=1, label="2020-02-01"];`,
+	} {
+		if !strings.Contains(dot, line+"\n") {
+			t.Errorf("Expected the line %q in\n%s", line, dot)
+		}
+	}
+	if strings.Count(dot, "--") != 3 {
+		t.Errorf("Expected 3 edges, got\n%s", dot)
+	}
+}
+
+func TestExportCoBillsGraphML(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ExportCoBillsGraphMLHandler(rr, httptest.NewRequest("GET", "/export/cobills.graphml", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/graphml+xml") {
+		t.Errorf("Expected a GraphML content type, got %q", ct)
+	}
+
+	var doc graphML
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid GraphML: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("Expected 3 artists and 2 edges, got %+v", doc.Graph)
+	}
+	if e := doc.Graph.Edges[0]; e.Source != "a1" || e.Target != "a4" || e.Data[0].Value != "1" {
+		t.Errorf("Expected Queen and Genesis joined once, got %+v", e)
+	}
+
+	rr = httptest.NewRecorder()
+	ExportCoBillsDOTHandler(rr, httptest.NewRequest("GET", "/export/cobills.dot", nil))
+	if body := rr.Body.String(); !strings.Contains(body, `a4 -- a5 [weight=1, label="2019-10-12"];`) {
+		t.Errorf("Expected Genesis and Phil Collins in the DOT export, got\n%s", body)
+	}
+}
+
+func TestGetCoBillsHandler(t *testing.T) {
+	rr := serveAPI(GetCoBillsHandler, "GET", "/api/v1/cobills?artist=5")
+	var coBills []CoBill
+	decodeAPIResponse(t, rr, &coBills)
+	if len(coBills) != 1 || coBills[0].Location != "berlin-germany" {
+		t.Errorf("Expected the Berlin bill of Phil Collins, got %+v", coBills)
+	}
+
+	rr = negotiated(ServeArtistDetails, "/artist/1", "text/html")
+	if body := rr.Body.String(); !strings.Contains(body, `<a href="/artist/4">Genesis</a>`) {
+		t.Errorf("Expected the artist page to list the shared bills")
+	}
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// maxCompare is the number of artists that can be compared at once
+const maxCompare = 6
+
+var errTooManyCompared = fmt.Errorf("at most %d artists can be compared", maxCompare)
+
+// ComparedArtist is an artist with the figures shown side by side
+type ComparedArtist struct {
+	api.Artist
+	Concerts     int      `json:"concerts"`
+	Countries    []string `json:"countries"`
+	FirstConcert string   `json:"firstConcert"`
+	LastConcert  string   `json:"lastConcert"`
+}
+
+// SharedVenue is a location played by several of the compared artists
+type SharedVenue struct {
+	Location string   `json:"location"`
+	Name     string   `json:"name"`
+	Artists  []string `json:"artists"`
+}
+
+// SharedDate is a day on which several of the compared artists played. Together is
+// true when they all played the same location that day.
+type SharedDate struct {
+	Date     string            `json:"date"`
+	Together bool              `json:"together"`
+	Concerts []TimelineConcert `json:"concerts"`
+}
+
+// Comparison puts artists side by side
+type Comparison struct {
+	Artists      []ComparedArtist `json:"artists"`
+	SharedVenues []SharedVenue    `json:"sharedVenues"`
+	SharedDates  []SharedDate     `json:"sharedDates"`
+}
+
+// CompareChoice is an artist of the comparison form
+type CompareChoice struct {
+	ID       int
+	Name     string
+	Selected bool
+}
+
+type ComparePageData struct {
+	Comparison
+	Choices []CompareChoice
+}
+
+// parseCompareIDs reads the artist IDs to compare. They may be comma separated,
+// "ids=1,5", or repeated, "ids=1&ids=5" as sent by the form. Duplicates are dropped.
+func parseCompareIDs(values url.Values) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, value := range values["ids"] {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := strconv.Atoi(s)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid artist ID %q", s)
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > maxCompare {
+		return nil, errTooManyCompared
+	}
+	return ids, nil
+}
+
+// selectArtists looks up the artists to compare, in the order of the IDs
+func selectArtists(artists []api.Artist, ids []int) ([]api.Artist, error) {
+	selected := make([]api.Artist, 0, len(ids))
+	for _, id := range ids {
+		a, ok := findArtist(artists, id)
+		if !ok {
+			return nil, fmt.Errorf("artist %d not found", id)
+		}
+		selected = append(selected, a)
+	}
+	return selected, nil
+}
+
+// buildComparison compares the artists, finding the locations and days they shared
+func buildComparison(artists []api.Artist, relations []api.Relation) Comparison {
+	comparison := Comparison{Artists: []ComparedArtist{}, SharedVenues: []SharedVenue{}, SharedDates: []SharedDate{}}
+
+	venues := map[string][]string{}
+	days := map[string][]TimelineConcert{}
+	for _, a := range artists {
+		concerts := artistConcerts(relations, a.ID)
+		compared := ComparedArtist{Artist: a, Concerts: len(concerts), Countries: getTourStats(a.ID).Countries}
+		if len(concerts) > 0 {
+			compared.FirstConcert = concerts[0].Date
+			compared.LastConcert = concerts[len(concerts)-1].Date
+		}
+		comparison.Artists = append(comparison.Artists, compared)
+
+		played := map[string]bool{}
+		for _, c := range concerts {
+			if !played[c.Location] {
+				played[c.Location] = true
+				venues[c.Location] = append(venues[c.Location], a.Name)
+			}
+			days[c.Date] = append(days[c.Date], TimelineConcert{Concert: c, Artist: a.Name})
+		}
+	}
+
+	for location, names := range venues {
+		if len(names) > 1 {
+			comparison.SharedVenues = append(comparison.SharedVenues, SharedVenue{Location: location, Name: formatLocation(location), Artists: names})
+		}
+	}
+	sort.Slice(comparison.SharedVenues, func(i, j int) bool {
+		return comparison.SharedVenues[i].Name < comparison.SharedVenues[j].Name
+	})
+
+	for date, concerts := range days {
+		together := true
+		artistIDs := map[int]bool{}
+		for _, c := range concerts {
+			artistIDs[c.ArtistID] = true
+			together = together && c.Location == concerts[0].Location
+		}
+		if len(artistIDs) > 1 {
+			comparison.SharedDates = append(comparison.SharedDates, SharedDate{Date: date, Together: together, Concerts: concerts})
+		}
+	}
+	sort.Slice(comparison.SharedDates, func(i, j int) bool {
+		return comparison.SharedDates[i].Date < comparison.SharedDates[j].Date
+	})
+	return comparison
+}
+
+// GetComparisonHandler handles the /api/v1/compare route
+func GetComparisonHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	ids, err := parseCompareIDs(r.URL.Query())
+	if err == nil && len(ids) < 2 {
+		err = fmt.Errorf("ids must list at least 2 artists")
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	selected, err := selectArtists(artists, ids)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, buildComparison(selected, relations), nil)
+}
+
+// ServeCompare handles the /compare page. The artists to compare are picked from a
+// form; with fewer than two the page only shows the form.
+func ServeCompare(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	// Errors use fixed messages so the page never echoes the requested IDs
+	ids, err := parseCompareIDs(r.URL.Query())
+	if errors.Is(err, errTooManyCompared) {
+		ErrorHandler(w, fmt.Sprintf("At most %d artists can be compared", maxCompare), http.StatusBadRequest, true, true)
+		return
+	}
+	if err != nil {
+		ErrorHandler(w, "Invalid artist ID", http.StatusBadRequest, true, true)
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	selected, err := selectArtists(artists, ids)
+	if err != nil {
+		ErrorHandler(w, "Artist not found", http.StatusNotFound, true, true)
+		return
+	}
+
+	data := ComparePageData{}
+	if len(selected) > 1 {
+		data.Comparison = buildComparison(selected, relations)
+	}
+	chosen := map[int]bool{}
+	for _, id := range ids {
+		chosen[id] = true
+	}
+	for _, a := range artists {
+		data.Choices = append(data.Choices, CompareChoice{ID: a.ID, Name: a.Name, Selected: chosen[a.ID]})
+	}
+	sort.Slice(data.Choices, func(i, j int) bool { return data.Choices[i].Name < data.Choices[j].Name })
+
+	renderTemplate(w, http.StatusOK, "templates/compare.html", data)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseCompareIDs(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+		valid    bool
+	}{
+		{"ids=1,5,12", "[1 5 12]", true},
+		{"ids=1&ids=5", "[1 5]", true},
+		{"ids=3,+3,,4", "[3 4]", true},
+		{"", "[]", true},
+		{"ids=1,x", "", false},
+		{"ids=0", "", false},
+		{"ids=1,2,3,4,5,6,7", "", false},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		ids, err := parseCompareIDs(values)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got error %v", tt.query, tt.valid, err)
+			continue
+		}
+		if tt.valid && fmt.Sprint(ids) != tt.expected {
+			t.Errorf("%s: expected %s, got %v", tt.query, tt.expected, ids)
+		}
+	}
+}
+
+func TestBuildComparison(t *testing.T) {
+	selected, err := selectArtists(fixtureArtists, []int{3, 4, 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	comparison := buildComparison(selected, fixtureRelations)
+
+	if len(comparison.Artists) != 3 || comparison.Artists[0].Name != "Pink Floyd" {
+		t.Fatalf("Expected the artists in the requested order, got %+v", comparison.Artists)
+	}
+	pinkFloyd := comparison.Artists[0]
+	if pinkFloyd.Concerts != 4 || len(pinkFloyd.Countries) != 3 || pinkFloyd.FirstConcert != "2019-04-14" || pinkFloyd.LastConcert != "2019-04-25" {
+		t.Errorf("Unexpected figures for Pink Floyd: %+v", pinkFloyd)
+	}
+
+	if len(comparison.SharedVenues) != 2 || comparison.SharedVenues[0].Name != "Berlin, Germany" || comparison.SharedVenues[1].Name != "London, UK" {
+		t.Fatalf("Expected Berlin and London as shared venues, got %+v", comparison.SharedVenues)
+	}
+	if artists := strings.Join(comparison.SharedVenues[1].Artists, ", "); artists != "Pink Floyd, Genesis, Phil Collins" {
+		t.Errorf("Expected all three artists in London, got %s", artists)
+	}
+
+	if len(comparison.SharedDates) != 1 {
+		t.Fatalf("Expected one overlapping date, got %+v", comparison.SharedDates)
+	}
+	if shared := comparison.SharedDates[0]; shared.Date != "2019-10-12" || !shared.Together || len(shared.Concerts) != 2 {
+		t.Errorf("Expected Genesis and Phil Collins together in Berlin, got %+v", shared)
+	}
+
+	if _, err := selectArtists(fixtureArtists, []int{1, 999}); err == nil || !strings.Contains(err.Error(), "999") {
+		t.Errorf("Expected an error naming the unknown artist, got %v", err)
+	}
+}
+
+func TestGetComparisonHandler(t *testing.T) {
+	rr := serveAPI(GetComparisonHandler, "GET", "/api/v1/compare?ids=1,2")
+	var comparison Comparison
+	decodeAPIResponse(t, rr, &comparison)
+	if len(comparison.Artists) != 2 || len(comparison.SharedVenues) != 0 || len(comparison.SharedDates) != 0 {
+		t.Errorf("Expected Queen and SOJA to share nothing, got %+v", comparison)
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/compare?ids=1":     http.StatusBadRequest,
+		"/api/v1/compare?ids=1,abc": http.StatusBadRequest,
+		"/api/v1/compare?ids=1,999": http.StatusNotFound,
+	} {
+		rr := serveAPI(GetComparisonHandler, "GET", target)
+		if rr.Code != status {
+			t.Errorf("%s: expected status %v; got %v", target, status, rr.Code)
+		}
+		decodeAPIError(t, rr)
+	}
+}
+
+func TestServeCompare(t *testing.T) {
+	rr := serveAPI(ServeCompare, "GET", "/compare")
+	if body := rr.Body.String(); !strings.Contains(body, "Pick at least two artists") || !strings.Contains(body, `value="3"`) {
+		t.Errorf("Expected the form without a comparison")
+	}
+
+	rr = serveAPI(ServeCompare, "GET", "/compare?ids=4&ids=5")
+	body := rr.Body.String()
+	if !strings.Contains(body, `value="4" checked`) || !strings.Contains(body, "2019-10-12 <strong>together</strong>") {
+		t.Errorf("Expected the comparison of Genesis and Phil Collins")
+	}
+
+	rr = serveAPI(ServeCompare, "GET", "/compare?ids=4,999")
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "Artist not found") || strings.Contains(rr.Body.String(), "999") {
+		t.Errorf("Expected a fixed not found error for an unknown artist, got %v", rr.Code)
+	}
+	if rr := serveAPI(ServeCompare, "GET", "/compare?ids=999,1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found for an unknown artist; got %v", rr.Code)
+	}
+	rr = serveAPI(ServeCompare, "GET", "/compare?ids="+url.QueryEscape("1,<script>"))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid artist ID") || strings.Contains(rr.Body.String(), "script&gt;") {
+		t.Errorf("Expected a fixed error for an invalid ID, got %v", rr.Code)
+	}
+	rr = serveAPI(ServeCompare, "GET", "/compare?ids=1,2,3,4,5,6,7")
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "At most 6 artists") {
+		t.Errorf("Expected an error for too many artists, got %v", rr.Code)
+	}
+}
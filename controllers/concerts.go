@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// Concert is a single dated performance of an artist, flattened out of Relation.DatesLocations
+type Concert struct {
+	ArtistID int       `json:"artistId"`
+	Location string    `json:"location"`
+	City     string    `json:"city"`
+	Country  string    `json:"country"`
+	Date     string    `json:"date"`
+	Time     time.Time `json:"-"`
+}
+
+// ArtistConcerts groups the concerts of one artist that matched a concert search
+type ArtistConcerts struct {
+	Artist   api.Artist `json:"artist"`
+	Concerts []Concert  `json:"concerts"`
+}
+
+// ConcertFilter restricts concerts by place and by an inclusive date range.
+// Zero values disable the corresponding restriction.
+type ConcertFilter struct {
+	Location string
+	From     time.Time
+	To       time.Time
+}
+
+type ConcertsPageData struct {
+	Location string
+	From     string
+	To       string
+	Searched bool
+	Error    string
+	Results  []ArtistConcerts
+	// ExportURL downloads the concerts of the search as CSV
+	ExportURL string
+}
+
+// playedInPattern recognises queries like "who played in Germany in 2019"
+var playedInPattern = regexp.MustCompile(`(?i)^\s*(?:who\s+)?played\s+in\s+(.+?)(?:\s+in\s+(\d{4}))?\s*\??\s*$`)
+
+// parseConcertDate parses the DD-MM-YYYY dates of the API, ignoring the leading
+// "*" used in the dates endpoint
+func parseConcertDate(s string) (time.Time, error) {
+	return time.Parse("02-01-2006", strings.TrimPrefix(strings.TrimSpace(s), "*"))
+}
+
+// splitLocation splits an API location such as "north_carolina-usa" into its city and country
+func splitLocation(location string) (string, string) {
+	location = strings.ToLower(location)
+	city, country := location, ""
+	if i := strings.LastIndex(location, "-"); i >= 0 {
+		city, country = location[:i], location[i+1:]
+	}
+	return strings.ReplaceAll(city, "_", " "), strings.ReplaceAll(country, "_", " ")
+}
+
+// formatLocation turns an API location into a display name, e.g. "North Carolina, USA"
+func formatLocation(location string) string {
+	city, country := splitLocation(location)
+	if country == "" {
+		return titleCase(city)
+	}
+	return titleCase(city) + ", " + formatCountry(country)
+}
+
+// titleCase upper-cases the first letter of every word
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// buildConcerts flattens the relations into concerts sorted by date, artist and location.
+// Dates that cannot be parsed are logged and skipped.
+func buildConcerts(relations []api.Relation) []Concert {
+	var concerts []Concert
+	for _, rel := range relations {
+		for location, dates := range rel.DatesLocations {
+			city, country := splitLocation(location)
+			for _, d := range dates {
+				t, err := parseConcertDate(d)
+				if err != nil {
+					log.Printf("Skipping concert of artist %d at %s: invalid date %q", rel.ID, location, d)
+					continue
+				}
+				concerts = append(concerts, Concert{
+					ArtistID: rel.ID,
+					Location: location,
+					City:     city,
+					Country:  country,
+					Date:     t.Format("2006-01-02"),
+					Time:     t,
+				})
+			}
+		}
+	}
+	sortConcerts(concerts)
+	return concerts
+}
+
+// sortConcerts orders concerts chronologically, breaking ties by artist and location
+func sortConcerts(concerts []Concert) {
+	sort.Slice(concerts, func(i, j int) bool {
+		a, b := concerts[i], concerts[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.ArtistID != b.ArtistID {
+			return a.ArtistID < b.ArtistID
+		}
+		return a.Location < b.Location
+	})
+}
+
+// Matches reports whether the concert satisfies the filter
+func (f ConcertFilter) Matches(c Concert) bool {
+	if f.Location != "" {
+		place := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(f.Location), "_", " "))
+		if !strings.Contains(c.City, place) && !strings.Contains(c.Country, place) {
+			return false
+		}
+	}
+	if !f.From.IsZero() && c.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && c.Time.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// searchConcerts returns the artists having at least one concert matching the filter,
+// each with its matching concerts, ordered by their first matching concert
+func searchConcerts(artists []api.Artist, concerts []Concert, filter ConcertFilter) []ArtistConcerts {
+	byID := make(map[int]int, len(artists))
+	for i, a := range artists {
+		byID[a.ID] = i
+	}
+
+	// concerts are sorted by date, so artists are appended in order of their first match
+	var results []ArtistConcerts
+	resultIndex := make(map[int]int)
+	for _, c := range concerts {
+		if !filter.Matches(c) {
+			continue
+		}
+		i, ok := byID[c.ArtistID]
+		if !ok {
+			continue
+		}
+		j, seen := resultIndex[c.ArtistID]
+		if !seen {
+			j = len(results)
+			resultIndex[c.ArtistID] = j
+			results = append(results, ArtistConcerts{Artist: artists[i]})
+		}
+		results[j].Concerts = append(results[j].Concerts, c)
+	}
+	return results
+}
+
+// searchLocations answers the location: search qualifier with the artists who played
+// a city or country containing the place, ordered by their first concert there
+func searchLocations(artists []api.Artist, relations []api.Relation, place string) []ArtistResult {
+	var results []ArtistResult
+	for _, found := range searchConcerts(artists, buildConcerts(relations), ConcertFilter{Location: place}) {
+		name := formatLocation(found.Concerts[0].Location)
+		match := matchField("location", name, strings.ReplaceAll(strings.TrimSpace(place), "_", " "))
+		if match == nil {
+			match = &SearchMatch{Field: "location", Value: name, Start: 0, End: len(name)}
+		}
+		results = append(results, ArtistResult{Artist: found.Artist, Match: match})
+	}
+	return results
+}
+
+// parseDateBound parses a YYYY, YYYY-MM or YYYY-MM-DD bound. Upper bounds are
+// extended to the last day of the given year or month.
+func parseDateBound(s string, upper bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+	}{
+		{"2006-01-02", 0, 0},
+		{"2006-01", 0, 1},
+		{"2006", 1, 0},
+	}
+	for _, l := range layouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+		if upper && (l.years != 0 || l.months != 0) {
+			t = t.AddDate(l.years, l.months, -1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", s)
+}
+
+// parseConcertFilter builds a filter from the location, from and to parameters.
+// A q parameter such as "played in Germany in 2019" may be given instead.
+func parseConcertFilter(values url.Values) (ConcertFilter, error) {
+	location, from, to := values.Get("location"), values.Get("from"), values.Get("to")
+	if q := values.Get("q"); q != "" {
+		place, year, ok := parsePlayedIn(q)
+		if !ok {
+			return ConcertFilter{}, fmt.Errorf("could not understand %q, try \"played in Germany in 2019\"", q)
+		}
+		location, from, to = place, year, year
+	}
+
+	var filter ConcertFilter
+	var err error
+	filter.Location = location
+	if filter.From, err = parseDateBound(from, false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseDateBound(to, true); err != nil {
+		return filter, err
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, fmt.Errorf("the end date must not be before the start date")
+	}
+	return filter, nil
+}
+
+// parsePlayedIn extracts the place and optional year of a "played in" query
+func parsePlayedIn(query string) (string, string, bool) {
+	m := playedInPattern.FindStringSubmatch(query)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// playedInURL returns the concerts page URL answering a "played in" query
+func playedInURL(place, year string) string {
+	values := url.Values{}
+	values.Set("location", place)
+	if year != "" {
+		values.Set("from", year)
+		values.Set("to", year)
+	}
+	return "/concerts?" + values.Encode()
+}
+
+// ServeConcerts handles the /concerts page
+func ServeConcerts(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	values := r.URL.Query()
+	data := ConcertsPageData{
+		Location:  values.Get("location"),
+		From:      values.Get("from"),
+		To:        values.Get("to"),
+		ExportURL: "/export/concerts.csv?" + r.URL.RawQuery,
+	}
+	if place, year, ok := parsePlayedIn(values.Get("q")); ok {
+		data.Location, data.From, data.To = place, year, year
+	}
+	data.Searched = data.Location != "" || data.From != "" || data.To != "" || values.Get("q") != ""
+
+	filter, err := parseConcertFilter(values)
+	if err != nil {
+		data.Error = err.Error()
+		renderTemplate(w, http.StatusBadRequest, "templates/concerts.html", data)
+		return
+	}
+
+	if data.Searched {
+		artists, _, _, relations := getCachedData()
+		data.Results = searchConcerts(artists, buildConcerts(relations), filter)
+	}
+
+	renderTemplate(w, http.StatusOK, "templates/concerts.html", data)
+}
+
+// SearchConcertsHandler handles the /api/v1/concerts route
+func SearchConcertsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	filter, err := parseConcertFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	results := searchConcerts(artists, buildConcerts(relations), filter)
+	if results == nil {
+		results = []ArtistConcerts{}
+	}
+	writeJSON(w, http.StatusOK, results, nil)
+}
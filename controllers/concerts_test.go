@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+var concertArtists = []api.Artist{
+	{ID: 1, Name: "Queen"},
+	{ID: 2, Name: "Scorpions"},
+}
+
+var concertRelations = []api.Relation{
+	{ID: 1, DatesLocations: map[string][]string{
+		"berlin-germany":     {"20-06-2019"},
+		"north_carolina-usa": {"23-08-2019"},
+	}},
+	{ID: 2, DatesLocations: map[string][]string{
+		"hanover-germany": {"05-01-2019", "12-03-2020"},
+		"osaka-japan":     {"*bad-date"},
+	}},
+}
+
+func TestBuildConcerts(t *testing.T) {
+	concerts := buildConcerts(concertRelations)
+	if len(concerts) != 4 {
+		t.Fatalf("Expected 4 concerts (invalid date skipped), got %d", len(concerts))
+	}
+	first := concerts[0]
+	if first.ArtistID != 2 || first.Date != "2019-01-05" || first.City != "hanover" || first.Country != "germany" {
+		t.Errorf("Expected first concert to be Scorpions in Hanover on 2019-01-05, got %+v", first)
+	}
+	for i := 1; i < len(concerts); i++ {
+		if concerts[i].Time.Before(concerts[i-1].Time) {
+			t.Errorf("Expected concerts sorted by date, got %s before %s", concerts[i-1].Date, concerts[i].Date)
+		}
+	}
+}
+
+func TestSearchConcertsPlayedInGermany2019(t *testing.T) {
+	filter, err := parseConcertFilter(url.Values{"q": {"Who played in Germany in 2019?"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	results := searchConcerts(concertArtists, buildConcerts(concertRelations), filter)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 artists, got %d", len(results))
+	}
+	if results[0].Artist.Name != "Scorpions" || len(results[0].Concerts) != 1 {
+		t.Errorf("Expected Scorpions first with one 2019 concert, got %+v", results[0])
+	}
+	if results[1].Artist.Name != "Queen" || results[1].Concerts[0].Location != "berlin-germany" {
+		t.Errorf("Expected Queen in Berlin second, got %+v", results[1])
+	}
+}
+
+func TestSearchConcertsByCity(t *testing.T) {
+	results := searchConcerts(concertArtists, buildConcerts(concertRelations), ConcertFilter{Location: "North_Carolina"})
+	if len(results) != 1 || results[0].Artist.ID != 1 {
+		t.Errorf("Expected only Queen to have played North Carolina, got %+v", results)
+	}
+}
+
+func TestSearchLocationQualifier(t *testing.T) {
+	results := searchArtists(fixtureArtists, fixtureRelations, "location:Kenya")
+	if len(results) != 2 || results[0].Name != "SOJA" || results[1].Name != "Phil Collins" {
+		t.Fatalf("Expected SOJA then Phil Collins in Kenya, got %+v", results)
+	}
+	if m := results[0].Match; m.Field != "location" || m.Value != "Nairobi, Kenya" || m.Text() != "Kenya" {
+		t.Errorf("Expected the match to highlight Kenya, got %+v", m)
+	}
+
+	results = searchArtists(fixtureArtists, fixtureRelations, "location:new_zealand")
+	if len(results) != 1 || results[0].Name != "Queen" || results[0].Match.Text() != "New Zealand" {
+		t.Errorf("Expected Queen to have played New Zealand, got %+v", results)
+	}
+	if results := searchArtists(fixtureArtists, fixtureRelations, "location:atlantis"); len(results) != 0 {
+		t.Errorf("Expected no artist to have played Atlantis, got %+v", results)
+	}
+}
+
+func TestParseDateBound(t *testing.T) {
+	tests := []struct {
+		input    string
+		upper    bool
+		expected string
+	}{
+		{"2019", false, "2019-01-01"},
+		{"2019", true, "2019-12-31"},
+		{"2020-02", true, "2020-02-29"},
+		{"2019-08-23", true, "2019-08-23"},
+	}
+	for _, tt := range tests {
+		got, err := parseDateBound(tt.input, tt.upper)
+		if err != nil {
+			t.Fatalf("parseDateBound(%q): unexpected error %v", tt.input, err)
+		}
+		if got.Format("2006-01-02") != tt.expected {
+			t.Errorf("parseDateBound(%q, %v): expected %s, got %s", tt.input, tt.upper, tt.expected, got.Format("2006-01-02"))
+		}
+	}
+
+	if _, err := parseDateBound("23/08/2019", false); err == nil {
+		t.Errorf("Expected an error for an invalid date")
+	}
+}
+
+func TestTitleCase(t *testing.T) {
+	tests := map[string]string{
+		"los angeles":   "Los Angeles",
+		"évry":          "Évry",
+		"são paulo":     "São Paulo",
+		"  new   york ": "New York",
+		"":              "",
+	}
+	for input, expected := range tests {
+		if got := titleCase(input); got != expected {
+			t.Errorf("titleCase(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestParseConcertFilterInvertedRange(t *testing.T) {
+	_, err := parseConcertFilter(url.Values{"from": {"2020"}, "to": {"2019"}})
+	if err == nil {
+		t.Errorf("Expected an error when the range ends before it starts")
+	}
+}
+
+func TestConcertFilterMatches(t *testing.T) {
+	c := Concert{City: "los angeles", Country: "usa", Time: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)}
+	from := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	if !(ConcertFilter{Location: "los angeles", From: from, To: from}).Matches(c) {
+		t.Errorf("Expected range bounds to be inclusive")
+	}
+	if (ConcertFilter{Location: "japan"}).Matches(c) {
+		t.Errorf("Expected concert in the USA not to match Japan")
+	}
+}
+
+func TestServeArtistsRedirectsPlayedInQueries(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?query="+url.QueryEscape("played in Germany in 2019"), nil)
+	rr := httptest.NewRecorder()
+	ServeArtists(rr, req)
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Expected status Found; got %v", rr.Code)
+	}
+	expected := "/concerts?from=2019&location=Germany&to=2019"
+	if loc := rr.Header().Get("Location"); loc != expected {
+		t.Errorf("Expected redirect to %s, got %s", expected, loc)
+	}
+}
+
+func TestServeConcertsEscapesInput(t *testing.T) {
+	payload := `"><script>alert(1)</script>`
+	for _, target := range []string{
+		"/concerts?location=" + url.QueryEscape(payload),
+		"/concerts?from=" + url.QueryEscape(payload),
+	} {
+		rr := httptest.NewRecorder()
+		ServeConcerts(rr, httptest.NewRequest("GET", target, nil))
+		body := rr.Body.String()
+		if strings.Contains(body, "<script>alert(1)") {
+			t.Errorf("%s: expected the input to be escaped", target)
+		}
+		if !strings.Contains(body, "&lt;script&gt;alert(1)") {
+			t.Errorf("%s: expected the escaped input in the page", target)
+		}
+	}
+}
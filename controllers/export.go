@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// allowExport rejects anything but GET and HEAD requests to the export routes
+func allowExport(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// ExportArtistsHandler handles the /export/artists.csv route. It accepts the
+// filters of /api/v1/artists, including the q or query search text.
+func ExportArtistsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowExport(w, r) {
+		return
+	}
+	initCache()
+	filter, err := parseArtistFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	artists, locations, _, relations := getCachedData()
+	results := applyArtistFilter(withLocationNames(artists, locations), relations, filter)
+	matched := make([]api.Artist, len(results))
+	for i, result := range results {
+		matched[i] = result.Artist
+	}
+	writeCSV(w, "artists.csv", artistCSVHeader, artistCSVRows(matched))
+}
+
+// ExportConcertsHandler handles the /export/concerts.csv route. It accepts the
+// location, from, to and q parameters of the concerts page.
+func ExportConcertsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowExport(w, r) {
+		return
+	}
+	initCache()
+	filter, err := parseConcertFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	var matched []Concert
+	for _, c := range buildConcerts(relations) {
+		if filter.Matches(c) {
+			matched = append(matched, c)
+		}
+	}
+	writeCSV(w, "concerts.csv", concertCSVHeader, concertCSVRows(artists, matched))
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// readCSV parses a CSV response, checking it is offered as a download
+func readCSV(t *testing.T, rr *httptest.ResponseRecorder, filename string) [][]string {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Expected a CSV response, got %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.Contains(cd, filename) {
+		t.Errorf("Expected an attachment named %s, got %q", filename, cd)
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	return records
+}
+
+func TestExportArtists(t *testing.T) {
+	rr := serveAPI(ExportArtistsHandler, "GET", "/export/artists.csv?members_max=1")
+	records := readCSV(t, rr, "artists.csv")
+	if len(records) != 2 || records[1][1] != "Phil Collins" {
+		t.Errorf("Expected only Phil Collins, got %v", records)
+	}
+
+	rr = serveAPI(ExportArtistsHandler, "GET", "/export/artists.csv")
+	if records := readCSV(t, rr, "artists.csv"); len(records) != len(fixtureArtists)+1 {
+		t.Errorf("Expected every artist without filters, got %d rows", len(records))
+	}
+
+	rr = serveAPI(ExportArtistsHandler, "GET", "/export/artists.csv?created_from=soon")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+}
+
+func TestExportConcerts(t *testing.T) {
+	rr := serveAPI(ExportConcertsHandler, "GET", "/export/concerts.csv?location=berlin&from=2019-10")
+	records := readCSV(t, rr, "concerts.csv")
+	if len(records) != 3 {
+		t.Fatalf("Expected the two Berlin concerts of October 2019, got %v", records)
+	}
+	if records[1][0] != "2019-10-12" || records[1][2] != "Genesis" || records[2][2] != "Phil Collins" {
+		t.Errorf("Unexpected rows %v", records[1:])
+	}
+
+	rr = serveAPI(ExportConcertsHandler, "POST", "/export/concerts.csv")
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed; got %v", rr.Code)
+	}
+}
+
+func TestArtistCSVRowsEscaping(t *testing.T) {
+	artist := api.Artist{ID: 7, Name: `Earth, Wind & "Fire"`, Members: []string{"Maurice White", "Verdine White"}, CreationDate: 1969, FirstAlbum: "01-01-1971"}
+	rr := httptest.NewRecorder()
+	writeCSV(rr, "", artistCSVHeader, artistCSVRows([]api.Artist{artist}))
+
+	if !strings.Contains(rr.Body.String(), `"Earth, Wind & ""Fire"""`) {
+		t.Errorf("Expected the name to be quoted, got %s", rr.Body.String())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil || records[1][1] != artist.Name || records[1][2] != "Maurice White; Verdine White" {
+		t.Errorf("Expected the row to round-trip, got %v (%v)", records, err)
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// favoritesFile is where the favorites of every browser session are kept
+const favoritesFile = "data/favorites.json"
+
+// FavoritesPageData holds the favorite artists matching the search of the page
+type FavoritesPageData struct {
+	AccountNav
+	Artists []ArtistResult
+	Query   string
+	Total   int
+}
+
+// ArtistCard is an artist card with the CSRF token of its favorite form
+type ArtistCard struct {
+	ArtistResult
+	CSRF string
+}
+
+// artistCard is the card template function, as templates cannot build structs
+func artistCard(result ArtistResult, csrf string) ArtistCard {
+	return ArtistCard{ArtistResult: result, CSRF: csrf}
+}
+
+// sessionFavoritesMaxAge is how long the favorites of a browser session are kept
+// after their last change: the session cookie is gone by then
+const sessionFavoritesMaxAge = sessionMaxAge * time.Second
+
+// maxSessionFavorites caps the number of browser sessions with favorites. The least
+// recently changed ones are dropped first.
+var maxSessionFavorites = 100000
+
+// favoriteData is the content of the favorites file. Updated records when the list
+// of every owner last changed, so the lists of browser sessions can expire.
+type favoriteData struct {
+	Owners  map[string][]int     `json:"owners"`
+	Updated map[string]time.Time `json:"updated"`
+}
+
+// favoriteStore keeps lists of favorite artist IDs by owner in a JSON file
+type favoriteStore struct {
+	mu sync.Mutex
+	jsonFile[favoriteData]
+}
+
+var favorites = newFavoriteStore(favoritesFile)
+
+func newFavoriteStore(path string) *favoriteStore {
+	return &favoriteStore{jsonFile: newJSONFile(path, "favorites", func(d *favoriteData) {
+		if d.Owners == nil {
+			d.Owners = map[string][]int{}
+		}
+		if d.Updated == nil {
+			d.Updated = map[string]time.Time{}
+		}
+	})}
+}
+
+// List returns the favorite artist IDs of an owner in the order they were added
+func (s *favoriteStore) List(owner string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return append([]int{}, s.data.Owners[owner]...)
+}
+
+// Set adds or removes an artist from the favorites of an owner
+func (s *favoriteStore) Set(owner string, artistID int, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+
+	ids := s.data.Owners[owner]
+	i := indexOfInt(ids, artistID)
+	switch {
+	case favorite && i < 0:
+		ids = append(ids, artistID)
+	case !favorite && i >= 0:
+		ids = append(ids[:i:i], ids[i+1:]...)
+	default:
+		return nil
+	}
+	s.put(owner, ids, time.Now())
+	return s.save()
+}
+
+// Merge adds the favorites of one owner to those of another and removes the first,
+// as when a browser session logs in to an account
+func (s *favoriteStore) Merge(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+
+	ids, ok := s.data.Owners[from]
+	if !ok {
+		return nil
+	}
+	merged := s.data.Owners[to]
+	for _, id := range ids {
+		if indexOfInt(merged, id) < 0 {
+			merged = append(merged, id)
+		}
+	}
+	now := time.Now()
+	s.put(from, nil, now)
+	s.put(to, merged, now)
+	return s.save()
+}
+
+// put replaces the list of an owner, removing owners without favorites, and drops
+// the expired browser sessions. The caller holds the lock and saves.
+func (s *favoriteStore) put(owner string, ids []int, now time.Time) {
+	if len(ids) == 0 {
+		delete(s.data.Owners, owner)
+		delete(s.data.Updated, owner)
+	} else {
+		s.data.Owners[owner] = ids
+		s.data.Updated[owner] = now.UTC()
+	}
+	s.prune(now)
+}
+
+// prune drops the favorites of browser sessions unchanged for sessionFavoritesMaxAge,
+// then the least recently changed ones above maxSessionFavorites. Accounts keep
+// theirs.
+func (s *favoriteStore) prune(now time.Time) {
+	var sessions []string
+	for owner := range s.data.Owners {
+		if !strings.HasPrefix(owner, "session:") {
+			continue
+		}
+		if now.Sub(s.data.Updated[owner]) > sessionFavoritesMaxAge {
+			delete(s.data.Owners, owner)
+			delete(s.data.Updated, owner)
+			continue
+		}
+		sessions = append(sessions, owner)
+	}
+	if len(sessions) <= maxSessionFavorites {
+		return
+	}
+	sort.Slice(sessions, func(i, j int) bool { return s.data.Updated[sessions[i]].Before(s.data.Updated[sessions[j]]) })
+	for _, owner := range sessions[:len(sessions)-maxSessionFavorites] {
+		delete(s.data.Owners, owner)
+		delete(s.data.Updated, owner)
+	}
+}
+
+// indexOfInt returns the index of n in ids, or -1
+func indexOfInt(ids []int, n int) int {
+	for i, id := range ids {
+		if id == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// favoritesOwner returns the key under which the favorites of a request are stored:
+// the account when logged in, else the browser session, or "" without a session
+func favoritesOwner(r *http.Request) string {
+	if user := currentUser(r); user != "" {
+		return "user:" + user
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && validSessionID(c.Value) {
+		return "session:" + c.Value
+	}
+	return ""
+}
+
+// favoriteIDs returns the favorite artist IDs of a request
+func favoriteIDs(r *http.Request) []int {
+	owner := favoritesOwner(r)
+	if owner == "" {
+		return []int{}
+	}
+	return favorites.List(owner)
+}
+
+// markFavorites flags the results that are favorites
+func markFavorites(results []ArtistResult, ids []int) {
+	for i := range results {
+		results[i].Favorite = indexOfInt(ids, results[i].ID) >= 0
+	}
+}
+
+// favoriteArtists returns the artists with the IDs, most recently added first.
+// Artists no longer in the data are skipped.
+func favoriteArtists(artists []api.Artist, ids []int) []api.Artist {
+	var selected []api.Artist
+	for i := len(ids) - 1; i >= 0; i-- {
+		if a, ok := findArtist(artists, ids[i]); ok {
+			selected = append(selected, a)
+		}
+	}
+	return selected
+}
+
+// localRedirect returns the path and query of a URL such as a Referer header, so
+// redirects cannot leave the site. fallback is used for anything else.
+func localRedirect(target, fallback string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Path == "" || u.Path[0] != '/' {
+		return fallback
+	}
+	local := url.URL{Path: u.Path, RawQuery: u.RawQuery}
+	return local.String()
+}
+
+// FavoritesHandler handles the /favorites route: GET shows the page and POST adds
+// or removes the artist of the form, then returns to the page the form was on
+func FavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		ServeFavorites(w, r)
+	case http.MethodPost:
+		updateFavorite(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateFavorite handles the add and remove forms
+func updateFavorite(w http.ResponseWriter, r *http.Request) {
+	session, _ := browserSession(w, r, false)
+	if !checkCSRF(r, session) {
+		ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+		return
+	}
+	id, err := strconv.Atoi(r.PostFormValue("artist"))
+	if err != nil {
+		ErrorHandler(w, "Invalid artist ID", http.StatusBadRequest, true, true)
+		return
+	}
+	action := r.PostFormValue("action")
+	if action != "add" && action != "remove" {
+		ErrorHandler(w, "Invalid favorite action", http.StatusBadRequest, true, true)
+		return
+	}
+
+	initCache()
+	artists, _, _, _ := getCachedData()
+	if _, ok := findArtist(artists, id); !ok && action == "add" {
+		ErrorHandler(w, "Artist not found", http.StatusNotFound, true, true)
+		return
+	}
+
+	if err := favorites.Set(favoritesOwner(r), id, action == "add"); err != nil {
+		log.Printf("Error saving favorites: %v", err)
+		ErrorHandler(w, "Your favorites could not be saved. Please try again later.", http.StatusInternalServerError, true, true)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.Referer(), "/favorites"), http.StatusSeeOther)
+}
+
+// ServeFavorites renders the favorites of the browser, filtered by the query
+// parameter like the search bar of the artists page
+func ServeFavorites(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	artists, locations, _, relations := getCachedData()
+	mine := favoriteArtists(withLocationNames(artists, locations), favoriteIDs(r))
+
+	query := r.URL.Query().Get("query")
+	results := searchArtists(mine, relations, query)
+	for i := range results {
+		results[i].Favorite = true
+	}
+	data := FavoritesPageData{AccountNav: accountNav(w, r), Artists: results, Query: query, Total: len(mine)}
+	renderTemplate(w, http.StatusOK, "templates/favorites.html", data)
+}
+
+// GetFavoritesHandler handles the /api/v1/favorites route, listing the favorite
+// artists of the account or session cookie, most recently added first
+func GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	artists, locations, _, _ := getCachedData()
+	mine := favoriteArtists(withLocationNames(artists, locations), favoriteIDs(r))
+	if mine == nil {
+		mine = []api.Artist{}
+	}
+	writeJSON(w, http.StatusOK, mine, nil)
+}
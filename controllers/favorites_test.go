@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTempFavorites points the favorites store at a temporary file for one test
+func withTempFavorites(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "favorites.json")
+	previous := favorites
+	favorites = newFavoriteStore(path)
+	t.Cleanup(func() { favorites = previous })
+	return path
+}
+
+// newBrowser opens the artists page without a cookie and returns the session
+// cookie it sets, as a first visit does
+func newBrowser(t *testing.T) *http.Cookie {
+	t.Helper()
+	rr := serveAPI(ServeArtists, "GET", "/")
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly || !validSessionID(cookies[0].Value) {
+		t.Fatalf("Expected a session cookie, got %+v", cookies)
+	}
+	if !strings.Contains(rr.Body.String(), `name="csrf_token" value="`+csrfToken(cookies[0].Value)+`"`) {
+		t.Fatalf("Expected the forms of the page to hold the CSRF token of the session")
+	}
+	return cookies[0]
+}
+
+// postFavorite submits the favorite form of a page with the CSRF token of the session
+func postFavorite(cookie *http.Cookie, artist, action, referer string) *httptest.ResponseRecorder {
+	form := url.Values{"artist": {artist}, "action": {action}}
+	if cookie != nil {
+		form.Set(csrfField, csrfToken(cookie.Value))
+	}
+	req := httptest.NewRequest("POST", "/favorites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	FavoritesHandler(rr, req)
+	return rr
+}
+
+func getWithCookie(handler http.HandlerFunc, target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestFavoriteStore(t *testing.T) {
+	path := withTempFavorites(t)
+	if err := favorites.Set("a", 3, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	favorites.Set("a", 1, true)
+	favorites.Set("a", 3, true)
+	favorites.Set("b", 2, true)
+	favorites.Set("b", 2, false)
+
+	if ids := favorites.List("a"); len(ids) != 2 || ids[0] != 3 || ids[1] != 1 {
+		t.Errorf("Expected [3 1] in the order added, got %v", ids)
+	}
+
+	// A new store reads back what was saved, without owners whose list is empty
+	reloaded := newFavoriteStore(path)
+	if ids := reloaded.List("a"); len(ids) != 2 {
+		t.Errorf("Expected the favorites to be persisted, got %v", ids)
+	}
+	data, _ := os.ReadFile(path)
+	var saved favoriteData
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved.Owners) != 1 || saved.Updated["a"].IsZero() {
+		t.Errorf("Expected one owner in the file with the time of its last change, got %s (%v)", data, err)
+	}
+}
+
+func TestFavoriteStoreExpiresSessions(t *testing.T) {
+	withTempFavorites(t)
+	now := time.Now()
+	favorites.load()
+	favorites.data.Owners["session:old"] = []int{1}
+	favorites.data.Updated["session:old"] = now.Add(-sessionFavoritesMaxAge - time.Hour)
+	favorites.data.Owners["session:recent"] = []int{2}
+	favorites.data.Updated["session:recent"] = now.Add(-sessionFavoritesMaxAge + time.Hour)
+	favorites.data.Owners["user:alice"] = []int{3}
+	favorites.data.Updated["user:alice"] = now.Add(-2 * sessionFavoritesMaxAge)
+
+	if err := favorites.Set("session:new", 4, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ids := favorites.List("session:old"); len(ids) != 0 {
+		t.Errorf("Expected the favorites of an expired session to be dropped, got %v", ids)
+	}
+	for _, owner := range []string{"session:recent", "session:new", "user:alice"} {
+		if ids := favorites.List(owner); len(ids) != 1 {
+			t.Errorf("Expected the favorites of %s to be kept, got %v", owner, ids)
+		}
+	}
+}
+
+func TestFavoriteStoreCapsSessions(t *testing.T) {
+	withTempFavorites(t)
+	previous := maxSessionFavorites
+	maxSessionFavorites = 2
+	t.Cleanup(func() { maxSessionFavorites = previous })
+
+	favorites.Set("user:alice", 1, true)
+	for _, owner := range []string{"session:a", "session:b", "session:c"} {
+		if err := favorites.Set(owner, 1, true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if ids := favorites.List("session:a"); len(ids) != 0 {
+		t.Errorf("Expected the least recently changed session to be dropped, got %v", ids)
+	}
+	for _, owner := range []string{"session:b", "session:c", "user:alice"} {
+		if ids := favorites.List(owner); len(ids) != 1 {
+			t.Errorf("Expected the favorites of %s to be kept, got %v", owner, ids)
+		}
+	}
+}
+
+func TestFavoritesFlow(t *testing.T) {
+	withTempFavorites(t)
+
+	session := newBrowser(t)
+	rr := postFavorite(session, "4", "add", "http://example.com/?query=gen")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/?query=gen" {
+		t.Fatalf("Expected a redirect back to the search, got %v %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = postFavorite(session, "1", "add", "")
+	if rr.Header().Get("Location") != "/favorites" || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected the existing session to be reused and a redirect to /favorites")
+	}
+
+	rr = getWithCookie(GetFavoritesHandler, "/api/v1/favorites", session)
+	var artists []struct{ Name string }
+	decodeAPIResponse(t, rr, &artists)
+	if len(artists) != 2 || artists[0].Name != "Queen" || artists[1].Name != "Genesis" {
+		t.Errorf("Expected Queen then Genesis, got %+v", artists)
+	}
+
+	rr = getWithCookie(FavoritesHandler, "/favorites?query=gen", session)
+	body := rr.Body.String()
+	if !strings.Contains(body, `href="/artist/4"`) || strings.Contains(body, `href="/artist/1"`) || !strings.Contains(body, `value="remove"`) {
+		t.Errorf("Expected the favorites page to filter the favorites")
+	}
+
+	rr = getWithCookie(ServeArtists, "/", session)
+	if body := rr.Body.String(); strings.Count(body, `value="remove"`) != 2 {
+		t.Errorf("Expected the two favorites to be marked on the artists page")
+	}
+	rr = getWithCookie(ServeArtistDetails, "/artist/4", session)
+	if !strings.Contains(rr.Body.String(), "Remove from favorites") {
+		t.Errorf("Expected the detail page to offer removing the favorite")
+	}
+
+	postFavorite(session, "4", "remove", "")
+	if ids := favorites.List("session:" + session.Value); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("Expected only Queen left, got %v", ids)
+	}
+
+	// Another browser has its own favorites
+	rr = serveAPI(GetFavoritesHandler, "GET", "/api/v1/favorites")
+	decodeAPIResponse(t, rr, &artists)
+	if len(artists) != 0 {
+		t.Errorf("Expected no favorites without a session, got %+v", artists)
+	}
+}
+
+func TestFavoritesErrors(t *testing.T) {
+	withTempFavorites(t)
+	session := newBrowser(t)
+	tests := []struct {
+		artist, action, message string
+	}{
+		{"abc", "add", "Invalid artist ID"},
+		{"1", "like", "Invalid favorite action"},
+		{"999", "add", "Artist not found"},
+	}
+	for _, tt := range tests {
+		rr := postFavorite(session, tt.artist, tt.action, "")
+		if !strings.Contains(rr.Body.String(), tt.message) {
+			t.Errorf("%s %s: expected %q", tt.action, tt.artist, tt.message)
+		}
+	}
+
+	// Forms posted without a session or with the token of another session fail
+	if rr := postFavorite(nil, "1", "add", ""); !strings.Contains(rr.Body.String(), expiredFormMessage) {
+		t.Errorf("Expected a form without a session to be refused")
+	}
+	other := &http.Cookie{Name: sessionCookie, Value: strings.Repeat("0", 32)}
+	form := url.Values{"artist": {"1"}, "action": {"add"}, csrfField: {csrfToken(session.Value)}}
+	req := httptest.NewRequest("POST", "/favorites", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(other)
+	rr := httptest.NewRecorder()
+	FavoritesHandler(rr, req)
+	if !strings.Contains(rr.Body.String(), expiredFormMessage) || len(favorites.List("session:"+other.Value)) != 0 {
+		t.Errorf("Expected a form with the token of another session to be refused")
+	}
+
+	rr = httptest.NewRecorder()
+	FavoritesHandler(rr, httptest.NewRequest("DELETE", "/favorites", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed; got %v", rr.Code)
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:8080/artist/3": "/artist/3",
+		"https://evil.example/x?y=1":     "/x?y=1",
+		"//evil.example":                 "/favorites",
+		"":                               "/favorites",
+		"javascript:alert(1)":            "/favorites",
+	}
+	for target, expected := range tests {
+		if got := localRedirect(target, "/favorites"); got != expected {
+			t.Errorf("%q: expected %q, got %q", target, expected, got)
+		}
+	}
+}
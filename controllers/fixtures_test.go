@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// Fixture data standing in for the upstream API so handlers can be tested offline
+var (
+	fixtureArtists = []api.Artist{
+		fixtureArtist(1, "Queen", 1970, "14-12-1973", "Freddie Mercury", "Brian May", "John Daecon", "Roger Meddows-Taylor"),
+		fixtureArtist(2, "SOJA", 1997, "05-06-2002", "Jacob Hemphill", "Bob Jefferson", "Ryan Berty", "Ken Brownell"),
+		fixtureArtist(3, "Pink Floyd", 1965, "05-08-1967", "Syd Barrett", "David Gilmour", "Roger Waters", "Richard Wright", "Nick Mason"),
+		fixtureArtist(4, "Genesis", 1967, "10-03-1969", "Phil Collins", "Tony Banks", "Mike Rutherford", "Peter Gabriel"),
+		fixtureArtist(5, "Phil Collins", 1975, "13-02-1981", "Phil Collins"),
+	}
+
+	fixtureRelations = []api.Relation{
+		{ID: 1, DatesLocations: map[string][]string{
+			"north_carolina-usa":  {"23-08-2019"},
+			"georgia-usa":         {"22-08-2019"},
+			"los_angeles-usa":     {"20-08-2019"},
+			"saitama-japan":       {"26-01-2020"},
+			"osaka-japan":         {"28-01-2020"},
+			"nagoya-japan":        {"30-01-2019"},
+			"penrose-new_zealand": {"07-02-2020"},
+			"dunedin-new_zealand": {"10-02-2020"},
+		}},
+		{ID: 2, DatesLocations: map[string][]string{
+			"playa_del_carmen-mexico":  {"05-12-2019"},
+			"papeete-french_polynesia": {"16-11-2019"},
+			"noumea-new_caledonia":     {"15-11-2019"},
+			"nairobi-kenya":            {"02-03-2020"},
+		}},
+		{ID: 3, DatesLocations: map[string][]string{
+			"london-uk":      {"14-04-2019", "15-04-2019"},
+			"paris-france":   {"20-04-2019"},
+			"berlin-germany": {"25-04-2019"},
+		}},
+		{ID: 4, DatesLocations: map[string][]string{
+			"los_angeles-usa": {"20-08-2019"},
+			"berlin-germany":  {"12-10-2019"},
+			"london-uk":       {"20-10-2019"},
+		}},
+		{ID: 5, DatesLocations: map[string][]string{
+			"berlin-germany": {"12-10-2019"},
+			"london-uk":      {"15-10-2019"},
+			"nairobi-kenya":  {"05-03-2020"},
+		}},
+	}
+
+	// Locations deliberately come back in a different order than the artists
+	fixtureLocations = []api.Location{
+		fixtureLocation(2), fixtureLocation(1), fixtureLocation(3), fixtureLocation(5), fixtureLocation(4),
+	}
+
+	fixtureDates = []api.Date{
+		fixtureDate(1), fixtureDate(2), fixtureDate(3), fixtureDate(4), fixtureDate(5),
+	}
+)
+
+func fixtureArtist(id int, name string, created int, firstAlbum string, members ...string) api.Artist {
+	return api.Artist{
+		ID:           id,
+		Name:         name,
+		Image:        fmt.Sprintf("https://groupietrackers.herokuapp.com/api/images/%d.jpeg", id),
+		CreationDate: created,
+		FirstAlbum:   firstAlbum,
+		Members:      members,
+		Locations:    fmt.Sprintf("https://groupietrackers.herokuapp.com/api/locations/%d", id),
+		ConcertDates: fmt.Sprintf("https://groupietrackers.herokuapp.com/api/dates/%d", id),
+		Relations:    fmt.Sprintf("https://groupietrackers.herokuapp.com/api/relation/%d", id),
+	}
+}
+
+func fixtureRelation(id int) api.Relation {
+	for _, rel := range fixtureRelations {
+		if rel.ID == id {
+			return rel
+		}
+	}
+	return api.Relation{ID: id}
+}
+
+func fixtureLocation(id int) api.Location {
+	loc := api.Location{ID: id, Dates: fmt.Sprintf("https://groupietrackers.herokuapp.com/api/dates/%d", id)}
+	for _, c := range buildConcerts([]api.Relation{fixtureRelation(id)}) {
+		if len(loc.Locations) == 0 || loc.Locations[len(loc.Locations)-1] != c.Location {
+			loc.Locations = append(loc.Locations, c.Location)
+		}
+	}
+	return loc
+}
+
+func fixtureDate(id int) api.Date {
+	date := api.Date{ID: id}
+	for _, c := range buildConcerts([]api.Relation{fixtureRelation(id)}) {
+		date.Dates = append(date.Dates, "*"+c.Time.Format("02-01-2006"))
+	}
+	return date
+}
+
+// seedTestCache fills the cache with the fixtures and marks it as fresh
+func seedTestCache() {
+	storeCache(fixtureArtists, fixtureLocations, fixtureDates, fixtureRelations)
+}
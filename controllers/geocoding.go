@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/geo"
+)
+
+// geoOverridesFile holds coordinates for locations missing from the gazetteer
+const geoOverridesFile = "data/geo_overrides.csv"
+
+var geocoder = newGeocoder()
+
+// GeocodingMiss is a location that could not be resolved and the artists who played it
+type GeocodingMiss struct {
+	Location string `json:"location"`
+	Artists  []int  `json:"artists"`
+}
+
+// GeocodingReport is the result of geocoding every location on a cache refresh.
+// Lookups lists every unresolved lookup since the server started.
+type GeocodingReport struct {
+	CheckedAt time.Time       `json:"checkedAt"`
+	Locations int             `json:"locations"`
+	Resolved  int             `json:"resolved"`
+	Misses    []GeocodingMiss `json:"misses"`
+	Lookups   []geo.Miss      `json:"lookups"`
+}
+
+func newGeocoder() *geo.Geocoder {
+	g, err := geo.New()
+	if err != nil {
+		log.Fatalf("Error loading the gazetteer: %v", err)
+	}
+	return g
+}
+
+// geocodeLocations reloads the overrides and geocodes every location of the datasets
+func geocodeLocations(locations []api.Location) GeocodingReport {
+	if err := geocoder.LoadOverrides(geoOverridesFile); err != nil {
+		log.Printf("Error loading geocoding overrides: %v", err)
+	}
+
+	artistsByLocation := map[string][]int{}
+	for _, l := range locations {
+		for _, location := range l.Locations {
+			key := strings.ToLower(location)
+			artistsByLocation[key] = append(artistsByLocation[key], l.ID)
+		}
+	}
+
+	report := GeocodingReport{CheckedAt: time.Now(), Locations: len(artistsByLocation), Misses: []GeocodingMiss{}}
+	for location, artists := range artistsByLocation {
+		if _, ok := geocoder.Lookup(location); ok {
+			report.Resolved++
+			continue
+		}
+		sort.Ints(artists)
+		report.Misses = append(report.Misses, GeocodingMiss{Location: location, Artists: artists})
+	}
+	sort.Slice(report.Misses, func(i, j int) bool { return report.Misses[i].Location < report.Misses[j].Location })
+	return report
+}
+
+// Log writes the misses of the report to the server log
+func (r GeocodingReport) Log() {
+	if len(r.Misses) == 0 {
+		log.Printf("Geocoded all %d locations", r.Locations)
+		return
+	}
+	for _, m := range r.Misses {
+		log.Printf("Geocoding: no coordinates for %q, add it to %s", m.Location, geoOverridesFile)
+	}
+	log.Printf("Geocoded %d of %d locations", r.Resolved, r.Locations)
+}
+
+// GeocodingHandler handles the /admin/geocoding route, returning the report of the last refresh
+func GeocodingHandler(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	cacheMutex.RLock()
+	report := geocodingReport
+	cacheMutex.RUnlock()
+	report.Lookups = geocoder.Misses()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding geocoding report to JSON: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+func TestGeocodeLocations(t *testing.T) {
+	report := geocodeLocations(fixtureLocations)
+	if len(report.Misses) != 0 || report.Resolved != report.Locations {
+		t.Errorf("Expected every fixture location to be resolved, got %+v", report)
+	}
+
+	locations := append(append([]api.Location{}, fixtureLocations...),
+		api.Location{ID: 6, Locations: []string{"atlantis-ocean", "london-uk"}},
+		api.Location{ID: 7, Locations: []string{"atlantis-ocean"}},
+	)
+	report = geocodeLocations(locations)
+	if report.Resolved != report.Locations-1 {
+		t.Errorf("Expected a single unresolved location, got %d of %d resolved", report.Resolved, report.Locations)
+	}
+	if len(report.Misses) != 1 || report.Misses[0].Location != "atlantis-ocean" || len(report.Misses[0].Artists) != 2 {
+		t.Errorf("Expected Atlantis to be reported with its 2 artists, got %+v", report.Misses)
+	}
+}
+
+func TestGeocodingHandler(t *testing.T) {
+	rr := serveAPI(GeocodingHandler, "GET", "/admin/geocoding")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	var report GeocodingReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Could not decode report: %v", err)
+	}
+	if report.Locations == 0 || report.Resolved != report.Locations {
+		t.Errorf("Expected the report of the fixtures, got %+v", report)
+	}
+}
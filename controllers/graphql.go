@@ -0,0 +1,736 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+const (
+	// gqlMaxDepth is the deepest selection a query may make
+	gqlMaxDepth = 7
+	// gqlMaxComplexity bounds the estimated number of fields a query may resolve
+	gqlMaxComplexity = 5000
+	// gqlDefaultListSize is the page size of list fields queried without "first",
+	// so the complexity estimate holds for every query
+	gqlDefaultListSize = 10
+	// gqlMaxPageSize is the largest value accepted for a "first" argument
+	gqlMaxPageSize = 100
+	// gqlMaxBodyBytes bounds the size of a POSTed request
+	gqlMaxBodyBytes = 64 << 10
+)
+
+// gqlFieldDef describes a field of the schema: its type in GraphQL notation and
+// the types of its arguments
+type gqlFieldDef struct {
+	Type string
+	Args map[string]string
+}
+
+var (
+	gqlPageArgs    = map[string]string{"first": "Int", "offset": "Int"}
+	gqlConcertArgs = map[string]string{"country": "String", "city": "String", "from": "String", "to": "String", "first": "Int", "offset": "Int"}
+)
+
+// gqlSchema lists the fields of every object type, starting at Query
+var gqlSchema = map[string]map[string]gqlFieldDef{
+	"Query": {
+		"artists":   {"[Artist!]!", map[string]string{"name": "String", "member": "String", "country": "String", "first": "Int", "offset": "Int"}},
+		"artist":    {"Artist", map[string]string{"id": "Int!"}},
+		"concerts":  {"[Concert!]!", gqlConcertArgs},
+		"locations": {"[Location!]!", map[string]string{"country": "String", "first": "Int", "offset": "Int"}},
+		"location":  {"Location", map[string]string{"slug": "String!"}},
+	},
+	"Artist": {
+		"id":           {"Int!", nil},
+		"name":         {"String!", nil},
+		"image":        {"String!", nil},
+		"creationDate": {"Int!", nil},
+		"firstAlbum":   {"String!", nil},
+		"members":      {"[Member!]!", gqlPageArgs},
+		"concerts":     {"[Concert!]!", gqlConcertArgs},
+		"locations":    {"[Location!]!", gqlPageArgs},
+	},
+	"Member": {
+		"name":   {"String!", nil},
+		"artist": {"Artist!", nil},
+	},
+	"Concert": {
+		"date":     {"String!", nil},
+		"artist":   {"Artist!", nil},
+		"location": {"Location!", nil},
+	},
+	"Location": {
+		"slug":     {"String!", nil},
+		"name":     {"String!", nil},
+		"city":     {"String!", nil},
+		"country":  {"String!", nil},
+		"concerts": {"[Concert!]!", map[string]string{"from": "String", "to": "String", "first": "Int", "offset": "Int"}},
+		"artists":  {"[Artist!]!", gqlPageArgs},
+	},
+}
+
+// gqlMember is a member of a band, as resolved by the Member type
+type gqlMember struct {
+	Name     string
+	ArtistID int
+}
+
+// GraphQLRequest is the body of a /graphql request
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQLResponse is the result of a GraphQL request
+type GraphQLResponse struct {
+	Data   interface{}    `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLError is an error in the format of the GraphQL specification
+type GraphQLError struct {
+	Message   string            `json:"message"`
+	Locations []GraphQLLocation `json:"locations,omitempty"`
+	Path      []interface{}     `json:"path,omitempty"`
+}
+
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlObject is a resolved object. It keeps the order of the selected fields,
+// which the specification asks responses to follow.
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlData indexes the cached data for the resolvers
+type gqlData struct {
+	artists            []api.Artist
+	artistByID         map[int]api.Artist
+	concerts           []Concert
+	concertsByArtist   map[int][]Concert
+	concertsByLocation map[string][]Concert
+	locations          []string
+}
+
+func newGQLData(artists []api.Artist, relations []api.Relation) *gqlData {
+	d := &gqlData{
+		artists:            artists,
+		artistByID:         make(map[int]api.Artist, len(artists)),
+		concerts:           buildConcerts(relations),
+		concertsByArtist:   map[int][]Concert{},
+		concertsByLocation: map[string][]Concert{},
+	}
+	for _, a := range artists {
+		d.artistByID[a.ID] = a
+	}
+	for _, c := range d.concerts {
+		d.concertsByArtist[c.ArtistID] = append(d.concertsByArtist[c.ArtistID], c)
+		if _, ok := d.concertsByLocation[c.Location]; !ok {
+			d.locations = append(d.locations, c.Location)
+		}
+		d.concertsByLocation[c.Location] = append(d.concertsByLocation[c.Location], c)
+	}
+	sort.Strings(d.locations)
+	return d
+}
+
+// gqlNamedType strips list and non-null markers from a type
+func gqlNamedType(t string) string {
+	return strings.Trim(t, "[]!")
+}
+
+func gqlIsList(t string) bool {
+	return strings.HasPrefix(t, "[")
+}
+
+// executeGraphQL parses, validates and executes a request against the data
+func executeGraphQL(req GraphQLRequest, data *gqlData) GraphQLResponse {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		gqlErr := GraphQLError{Message: err.Error()}
+		if se, ok := err.(*gqlSyntaxError); ok {
+			gqlErr.Locations = []GraphQLLocation{{se.Line, se.Column}}
+		}
+		return GraphQLResponse{Errors: []GraphQLError{gqlErr}}
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+
+	vars, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+
+	if errs := validateSelections("Query", op.Selections, vars); len(errs) > 0 {
+		return GraphQLResponse{Errors: errs}
+	}
+	if c := estimateComplexity("Query", op.Selections, vars); c > gqlMaxComplexity {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: fmt.Sprintf("query complexity %d exceeds the maximum of %d", c, gqlMaxComplexity)}}}
+	}
+
+	e := &gqlExecutor{data: data, vars: vars}
+	result := e.selectFields("Query", nil, op.Selections, nil)
+	return GraphQLResponse{Data: result, Errors: e.errors}
+}
+
+// selectOperation picks the operation to run from the document
+func selectOperation(doc *gqlDocument, name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document contains several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables checks the provided variables against the operation's definitions
+func coerceVariables(op *gqlOperation, provided map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range op.Variables {
+		value, ok := provided[def.Name]
+		if !ok && def.HasDefault {
+			value, ok = def.Default, true
+		}
+		if !ok || value == nil {
+			if def.NonNull {
+				return nil, fmt.Errorf("variable $%s of type %s! is required", def.Name, def.Type)
+			}
+			continue
+		}
+		coerced, err := coerceScalar(value, def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+		vars[def.Name] = coerced
+	}
+	return vars, nil
+}
+
+// coerceScalar converts a literal or JSON value to the Go value of a scalar type
+func coerceScalar(value interface{}, typeName string) (interface{}, error) {
+	switch typeName {
+	case "Int":
+		// Like the GraphQL specification, Int is a signed 32-bit integer
+		var n float64
+		switch v := value.(type) {
+		case int:
+			n = float64(v)
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return nil, fmt.Errorf("expected a value of type Int, got %v", value)
+		}
+		if n != math.Trunc(n) {
+			return nil, fmt.Errorf("expected a value of type Int, got %v", value)
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("integer %v does not fit in 32 bits", value)
+		}
+		return int(n), nil
+	case "Float":
+		switch n := value.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "String":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typeName)
+	}
+	return nil, fmt.Errorf("expected a value of type %s, got %v", typeName, value)
+}
+
+// fieldArgs resolves variables and coerces the field's arguments to their declared types
+func fieldArgs(f *gqlField, def gqlFieldDef, vars map[string]interface{}) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, arg := range f.Args {
+		argType, ok := def.Args[arg.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown argument %q on field %q", arg.Name, f.Name)
+		}
+		value := arg.Value
+		if v, isVar := value.(gqlVariable); isVar {
+			value = vars[string(v)]
+		}
+		if value == nil {
+			continue
+		}
+		coerced, err := coerceScalar(value, gqlNamedType(argType))
+		if err != nil {
+			return nil, fmt.Errorf("argument %q on field %q: %v", arg.Name, f.Name, err)
+		}
+		if err := checkPageArg(arg.Name, coerced); err != nil {
+			return nil, fmt.Errorf("argument %q on field %q: %v", arg.Name, f.Name, err)
+		}
+		args[arg.Name] = coerced
+	}
+	for name, argType := range def.Args {
+		if _, ok := args[name]; !ok && strings.HasSuffix(argType, "!") {
+			return nil, fmt.Errorf("argument %q of type %s is required on field %q", name, argType, f.Name)
+		}
+	}
+	return args, nil
+}
+
+// checkPageArg rejects negative first and offset arguments and pages larger than gqlMaxPageSize
+func checkPageArg(name string, value interface{}) error {
+	n, ok := value.(int)
+	if !ok || (name != "first" && name != "offset") {
+		return nil
+	}
+	if n < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	if name == "first" && n > gqlMaxPageSize {
+		return fmt.Errorf("must not exceed %d", gqlMaxPageSize)
+	}
+	return nil
+}
+
+func gqlFieldError(f *gqlField, format string, args ...interface{}) GraphQLError {
+	return GraphQLError{Message: fmt.Sprintf(format, args...), Locations: []GraphQLLocation{{f.Line, f.Column}}}
+}
+
+// validateSelections checks the selections against the schema. The depth limit is
+// enforced by the parser.
+func validateSelections(typeName string, selections []*gqlField, vars map[string]interface{}) []GraphQLError {
+	var errs []GraphQLError
+	for _, f := range selections {
+		if f.Name == "__typename" {
+			if f.Selections != nil {
+				errs = append(errs, gqlFieldError(f, "field \"__typename\" must not have a selection"))
+			}
+			continue
+		}
+		def, ok := gqlSchema[typeName][f.Name]
+		if !ok {
+			errs = append(errs, gqlFieldError(f, "cannot query field %q on type %q", f.Name, typeName))
+			continue
+		}
+		if _, err := fieldArgs(f, def, vars); err != nil {
+			errs = append(errs, gqlFieldError(f, "%v", err))
+		}
+
+		child := gqlNamedType(def.Type)
+		_, isObject := gqlSchema[child]
+		switch {
+		case isObject && f.Selections == nil:
+			errs = append(errs, gqlFieldError(f, "field %q of type %q must have a selection of subfields", f.Name, def.Type))
+		case !isObject && f.Selections != nil:
+			errs = append(errs, gqlFieldError(f, "field %q of type %q must not have a selection", f.Name, def.Type))
+		case isObject:
+			errs = append(errs, validateSelections(child, f.Selections, vars)...)
+		}
+	}
+	return errs
+}
+
+// estimateComplexity counts the fields a query may resolve, multiplying the
+// selections of list fields by their expected length. The count saturates at
+// math.MaxInt instead of overflowing.
+func estimateComplexity(typeName string, selections []*gqlField, vars map[string]interface{}) int {
+	total := 0
+	for _, f := range selections {
+		total = saturatingAdd(total, 1)
+		def, ok := gqlSchema[typeName][f.Name]
+		if !ok || f.Selections == nil {
+			continue
+		}
+		size := 1
+		if gqlIsList(def.Type) {
+			size = gqlDefaultListSize
+			if args, err := fieldArgs(f, def, vars); err == nil {
+				if first, ok := args["first"].(int); ok {
+					size = first
+				}
+			}
+		}
+		total = saturatingAdd(total, saturatingMul(size, estimateComplexity(gqlNamedType(def.Type), f.Selections, vars)))
+	}
+	return total
+}
+
+// saturatingAdd adds two non-negative numbers, returning math.MaxInt on overflow
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// saturatingMul multiplies two non-negative numbers, returning math.MaxInt on overflow
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+type gqlExecutor struct {
+	data   *gqlData
+	vars   map[string]interface{}
+	errors []GraphQLError
+}
+
+// selectFields resolves the selected fields of an object
+func (e *gqlExecutor) selectFields(typeName string, parent interface{}, selections []*gqlField, path []interface{}) *gqlObject {
+	obj := &gqlObject{values: map[string]interface{}{}}
+	for _, f := range selections {
+		key := f.ResponseKey()
+		fieldPath := append(append([]interface{}{}, path...), key)
+		if f.Name == "__typename" {
+			obj.set(key, typeName)
+			continue
+		}
+
+		def := gqlSchema[typeName][f.Name]
+		args, _ := fieldArgs(f, def, e.vars) // already validated
+		value, err := e.resolve(typeName, f.Name, parent, args)
+		if err != nil {
+			gqlErr := gqlFieldError(f, "%v", err)
+			gqlErr.Path = fieldPath
+			e.errors = append(e.errors, gqlErr)
+			obj.set(key, nil)
+			continue
+		}
+		obj.set(key, e.complete(gqlNamedType(def.Type), value, f, fieldPath))
+	}
+	return obj
+}
+
+// complete resolves the subfields of object values and lists of them
+func (e *gqlExecutor) complete(typeName string, value interface{}, f *gqlField, path []interface{}) interface{} {
+	if _, isObject := gqlSchema[typeName]; !isObject || value == nil {
+		return value
+	}
+	if list, ok := value.([]interface{}); ok {
+		completed := make([]interface{}, len(list))
+		for i, item := range list {
+			completed[i] = e.selectFields(typeName, item, f.Selections, append(append([]interface{}{}, path...), i))
+		}
+		return completed
+	}
+	return e.selectFields(typeName, value, f.Selections, path)
+}
+
+// resolve returns the value of a field of a parent object. Lists are returned as
+// []interface{} so that complete can walk them.
+func (e *gqlExecutor) resolve(typeName, field string, parent interface{}, args map[string]interface{}) (interface{}, error) {
+	d := e.data
+	switch typeName {
+	case "Query":
+		switch field {
+		case "artists":
+			var artists []api.Artist
+			for _, a := range d.artists {
+				if gqlArtistMatches(a, args, d) {
+					artists = append(artists, a)
+				}
+			}
+			return gqlPage(artists, args), nil
+		case "artist":
+			if a, ok := d.artistByID[args["id"].(int)]; ok {
+				return a, nil
+			}
+			return nil, nil
+		case "concerts":
+			return gqlConcerts(d.concerts, args)
+		case "locations":
+			var locations []string
+			for _, l := range d.locations {
+				_, country := splitLocation(l)
+				if c, ok := args["country"].(string); !ok || gqlSamePlace(country, c) {
+					locations = append(locations, l)
+				}
+			}
+			return gqlPage(locations, args), nil
+		case "location":
+			slug := strings.ToLower(args["slug"].(string))
+			if _, ok := d.concertsByLocation[slug]; ok {
+				return slug, nil
+			}
+			return nil, nil
+		}
+
+	case "Artist":
+		a := parent.(api.Artist)
+		switch field {
+		case "id":
+			return a.ID, nil
+		case "name":
+			return a.Name, nil
+		case "image":
+			return a.Image, nil
+		case "creationDate":
+			return a.CreationDate, nil
+		case "firstAlbum":
+			return a.FirstAlbum, nil
+		case "members":
+			members := make([]gqlMember, len(a.Members))
+			for i, m := range a.Members {
+				members[i] = gqlMember{Name: m, ArtistID: a.ID}
+			}
+			return gqlPage(members, args), nil
+		case "concerts":
+			return gqlConcerts(d.concertsByArtist[a.ID], args)
+		case "locations":
+			var locations []string
+			seen := map[string]bool{}
+			for _, c := range d.concertsByArtist[a.ID] {
+				if !seen[c.Location] {
+					seen[c.Location] = true
+					locations = append(locations, c.Location)
+				}
+			}
+			return gqlPage(locations, args), nil
+		}
+
+	case "Member":
+		m := parent.(gqlMember)
+		switch field {
+		case "name":
+			return m.Name, nil
+		case "artist":
+			return d.artistByID[m.ArtistID], nil
+		}
+
+	case "Concert":
+		c := parent.(Concert)
+		switch field {
+		case "date":
+			return c.Date, nil
+		case "artist":
+			return d.artistByID[c.ArtistID], nil
+		case "location":
+			return c.Location, nil
+		}
+
+	case "Location":
+		slug := parent.(string)
+		city, country := splitLocation(slug)
+		switch field {
+		case "slug":
+			return slug, nil
+		case "name":
+			return formatLocation(slug), nil
+		case "city":
+			return city, nil
+		case "country":
+			return country, nil
+		case "concerts":
+			return gqlConcerts(d.concertsByLocation[slug], args)
+		case "artists":
+			var artists []api.Artist
+			seen := map[int]bool{}
+			for _, c := range d.concertsByLocation[slug] {
+				if a, ok := d.artistByID[c.ArtistID]; ok && !seen[a.ID] {
+					seen[a.ID] = true
+					artists = append(artists, a)
+				}
+			}
+			return gqlPage(artists, args), nil
+		}
+	}
+	return nil, fmt.Errorf("no resolver for %s.%s", typeName, field)
+}
+
+// gqlSamePlace compares a city or country with a filter argument, ignoring case
+// and the underscores of the API
+func gqlSamePlace(place, filter string) bool {
+	return strings.EqualFold(place, strings.ReplaceAll(strings.TrimSpace(filter), "_", " "))
+}
+
+func gqlArtistMatches(a api.Artist, args map[string]interface{}, d *gqlData) bool {
+	if name, ok := args["name"].(string); ok && !strings.Contains(strings.ToLower(a.Name), strings.ToLower(name)) {
+		return false
+	}
+	if member, ok := args["member"].(string); ok {
+		found := false
+		for _, m := range a.Members {
+			if strings.Contains(strings.ToLower(m), strings.ToLower(member)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if country, ok := args["country"].(string); ok {
+		for _, c := range d.concertsByArtist[a.ID] {
+			if gqlSamePlace(c.Country, country) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// gqlConcerts filters concerts by the country, city, from and to arguments and paginates them
+func gqlConcerts(concerts []Concert, args map[string]interface{}) (interface{}, error) {
+	var from, to time.Time
+	var err error
+	if s, ok := args["from"].(string); ok {
+		if from, err = parseDateBound(s, false); err != nil {
+			return nil, err
+		}
+	}
+	if s, ok := args["to"].(string); ok {
+		if to, err = parseDateBound(s, true); err != nil {
+			return nil, err
+		}
+	}
+
+	var matched []Concert
+	for _, c := range concerts {
+		if country, ok := args["country"].(string); ok && !gqlSamePlace(c.Country, country) {
+			continue
+		}
+		if city, ok := args["city"].(string); ok && !gqlSamePlace(c.City, city) {
+			continue
+		}
+		if (!from.IsZero() && c.Time.Before(from)) || (!to.IsZero() && c.Time.After(to)) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	return gqlPage(matched, args), nil
+}
+
+// gqlPage applies the offset and first arguments to a list. Without first, the
+// page holds gqlDefaultListSize items.
+func gqlPage[T any](items []T, args map[string]interface{}) []interface{} {
+	start, end := 0, len(items)
+	if offset, ok := args["offset"].(int); ok && offset > 0 {
+		start = min(offset, end)
+	}
+	first := gqlDefaultListSize
+	if n, ok := args["first"].(int); ok && n >= 0 {
+		first = n
+	}
+	end = min(start+first, end)
+	page := make([]interface{}, 0, end-start)
+	for _, item := range items[start:end] {
+		page = append(page, item)
+	}
+	return page
+}
+
+// parseGraphQLRequest reads a request from a JSON POST body or from GET parameters
+func parseGraphQLRequest(r *http.Request) (GraphQLRequest, error) {
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid JSON body: %w", err)
+		}
+	case http.MethodGet:
+		values := r.URL.Query()
+		req.Query = values.Get("query")
+		req.OperationName = values.Get("operationName")
+		if v := values.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return req, fmt.Errorf("invalid variables: %v", err)
+			}
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return req, fmt.Errorf("a query is required")
+	}
+	return req, nil
+}
+
+// GraphQLHandler handles the /graphql route
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(GraphQLResponse{Errors: []GraphQLError{{Message: "method not allowed"}}})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, gqlMaxBodyBytes)
+	req, err := parseGraphQLRequest(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+
+	initCache()
+	artists, _, _, relations := getCachedData()
+	resp := executeGraphQL(req, newGQLData(artists, relations))
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding GraphQL response: %v", err)
+	}
+}
@@ -0,0 +1,594 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The parser below covers the part of the GraphQL query language the /graphql
+// endpoint needs: query operations with variables, aliases, arguments and nested
+// selection sets. Fragments, directives and mutations are rejected.
+
+type gqlDocument struct {
+	Operations []*gqlOperation
+}
+
+type gqlOperation struct {
+	Name       string
+	Variables  []gqlVariableDef
+	Selections []*gqlField
+}
+
+type gqlVariableDef struct {
+	Name       string
+	Type       string
+	NonNull    bool
+	Default    interface{}
+	HasDefault bool
+}
+
+type gqlField struct {
+	Alias      string
+	Name       string
+	Args       []gqlArgument
+	Selections []*gqlField
+	Line       int
+	Column     int
+}
+
+type gqlArgument struct {
+	Name  string
+	Value interface{}
+}
+
+// gqlVariable is a reference to an operation variable inside an argument value
+type gqlVariable string
+
+// gqlEnum is an enum value literal
+type gqlEnum string
+
+// ResponseKey is the key of the field in the result: its alias, or its name
+func (f *gqlField) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type gqlTokenKind int
+
+const (
+	tokEOF gqlTokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type gqlToken struct {
+	Kind   gqlTokenKind
+	Value  string
+	Line   int
+	Column int
+}
+
+// gqlSyntaxError is returned for documents that cannot be parsed
+type gqlSyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *gqlSyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type gqlLexer struct {
+	src    string
+	pos    int
+	line   int
+	column int
+}
+
+// next returns the next token, skipping whitespace, commas and comments
+func (l *gqlLexer) next() (gqlToken, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.column = 1
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.advance(1)
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		default:
+			return l.token()
+		}
+	}
+	return gqlToken{Kind: tokEOF, Line: l.line, Column: l.column}, nil
+}
+
+func (l *gqlLexer) advance(n int) {
+	l.pos += n
+	l.column += n
+}
+
+func (l *gqlLexer) errorf(format string, args ...interface{}) error {
+	return &gqlSyntaxError{Message: fmt.Sprintf(format, args...), Line: l.line, Column: l.column}
+}
+
+func (l *gqlLexer) token() (gqlToken, error) {
+	tok := gqlToken{Line: l.line, Column: l.column}
+	c := l.src[l.pos]
+	start := l.pos
+
+	switch {
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		l.advance(1)
+		tok.Kind, tok.Value = tokPunct, string(c)
+		return tok, nil
+
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		tok.Kind, tok.Value = tokPunct, "..."
+		return tok, nil
+
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		tok.Kind, tok.Value = tokName, l.src[start:l.pos]
+		return tok, nil
+
+	case c == '-' || isDigit(c):
+		return l.number(tok)
+
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return tok, l.errorf("block strings are not supported")
+		}
+		s, err := l.string()
+		tok.Kind, tok.Value = tokString, s
+		return tok, err
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return tok, l.errorf("unexpected character %q", r)
+}
+
+func (l *gqlLexer) number(tok gqlToken) (gqlToken, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return tok, l.errorf("invalid number")
+	}
+
+	tok.Kind = tokInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.advance(1)
+		if digits() == 0 {
+			return tok, l.errorf("invalid number")
+		}
+		tok.Kind = tokFloat
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return tok, l.errorf("invalid number")
+		}
+		tok.Kind = tokFloat
+	}
+	tok.Value = l.src[start:l.pos]
+	return tok, nil
+}
+
+func (l *gqlLexer) string() (string, error) {
+	l.advance(1) // opening quote
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return b.String(), nil
+		case c == '\n':
+			return "", l.errorf("unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return "", l.errorf("unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			l.advance(2)
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return "", l.errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return "", l.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				l.advance(4)
+			default:
+				return "", l.errorf("invalid escape \\%c", esc)
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			b.WriteString(l.src[l.pos : l.pos+size])
+			l.advance(size)
+		}
+	}
+	return "", l.errorf("unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type gqlParser struct {
+	lexer *gqlLexer
+	tok   gqlToken
+	// depth counts the selection sets, lists and objects being parsed
+	depth int
+}
+
+// parseGraphQL parses a query document
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: src, line: 1, column: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{}
+	for p.tok.Kind != tokEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.Operations = append(doc.Operations, op)
+	}
+	if len(doc.Operations) == 0 {
+		return nil, p.errorf("the document contains no operation")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return &gqlSyntaxError{Message: fmt.Sprintf(format, args...), Line: p.tok.Line, Column: p.tok.Column}
+}
+
+func (p *gqlParser) describe() string {
+	if p.tok.Kind == tokEOF {
+		return "end of document"
+	}
+	return fmt.Sprintf("%q", p.tok.Value)
+}
+
+// peek reports whether the current token is the given punctuator
+func (p *gqlParser) peek(punct string) bool {
+	return p.tok.Kind == tokPunct && p.tok.Value == punct
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q, found %s", punct, p.describe())
+	}
+	return p.advance()
+}
+
+// enter descends into a nested selection set or value, failing once the
+// document nests deeper than gqlMaxDepth
+func (p *gqlParser) enter() error {
+	p.depth++
+	if p.depth > gqlMaxDepth {
+		return p.errorf("query depth exceeds the maximum of %d", gqlMaxDepth)
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.Kind != tokName {
+		return "", p.errorf("expected a name, found %s", p.describe())
+	}
+	name := p.tok.Value
+	return name, p.advance()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{}
+	if p.peek("{") {
+		sel, err := p.selectionSet()
+		op.Selections = sel
+		return op, err
+	}
+
+	if p.tok.Kind != tokName {
+		return nil, p.errorf("expected an operation, found %s", p.describe())
+	}
+	switch p.tok.Value {
+	case "query":
+	case "mutation", "subscription":
+		return nil, p.errorf("%s operations are not supported", p.tok.Value)
+	case "fragment":
+		return nil, p.errorf("fragments are not supported")
+	default:
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind == tokName {
+		op.Name = p.tok.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		vars, err := p.variableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.Variables = vars
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+	sel, err := p.selectionSet()
+	op.Selections = sel
+	return op, err
+}
+
+func (p *gqlParser) variableDefinitions() ([]gqlVariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []gqlVariableDef
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var def gqlVariableDef
+		var err error
+		if def.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if p.peek("[") {
+			return nil, p.errorf("list variables are not supported")
+		}
+		if def.Type, err = p.name(); err != nil {
+			return nil, err
+		}
+		if p.peek("!") {
+			def.NonNull = true
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.Default, err = p.value(true); err != nil {
+				return nil, err
+			}
+			def.HasDefault = true
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *gqlParser) selectionSet() ([]*gqlField, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for !p.peek("}") {
+		if p.peek("...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("selection sets must not be empty")
+	}
+	return fields, p.advance()
+}
+
+func (p *gqlParser) field() (*gqlField, error) {
+	f := &gqlField{Line: p.tok.Line, Column: p.tok.Column}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f.Name = name
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f.Alias = name
+		if f.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			var arg gqlArgument
+			if arg.Name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if arg.Value, err = p.value(false); err != nil {
+				return nil, err
+			}
+			f.Args = append(f.Args, arg)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+	if p.peek("{") {
+		if f.Selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// value parses an argument value. Constant values, such as variable defaults,
+// may not reference variables.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case p.peek("$"):
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return gqlVariable(name), err
+
+	case p.peek("["):
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+
+	case p.peek("{"):
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+
+	case tok.Kind == tokInt:
+		n, err := strconv.ParseInt(tok.Value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", tok.Value)
+		}
+		return n, p.advance()
+
+	case tok.Kind == tokFloat:
+		f, err := strconv.ParseFloat(tok.Value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", tok.Value)
+		}
+		return f, p.advance()
+
+	case tok.Kind == tokString:
+		return tok.Value, p.advance()
+
+	case tok.Kind == tokName:
+		var v interface{}
+		switch tok.Value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = gqlEnum(tok.Value)
+		}
+		return v, p.advance()
+	}
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// postGraphQL sends a request to the GraphQL handler and decodes the response
+func postGraphQL(t *testing.T, req GraphQLRequest) (int, map[string]interface{}, []GraphQLError) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	GraphQLHandler(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+
+	var resp struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []GraphQLError         `json:"errors"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	return rr.Code, resp.Data, resp.Errors
+}
+
+func TestGraphQLArtistQuery(t *testing.T) {
+	query := `{ artist(id: 1) { name members { name } concerts(country: "japan") { date location { city } } } }`
+	_, data, errs := postGraphQL(t, GraphQLRequest{Query: query})
+	if len(errs) > 0 {
+		t.Fatalf("Expected no errors, got %+v", errs)
+	}
+
+	artist := data["artist"].(map[string]interface{})
+	if artist["name"] != "Queen" {
+		t.Errorf("Expected Queen, got %v", artist["name"])
+	}
+	if members := artist["members"].([]interface{}); len(members) != len(fixtureArtists[0].Members) {
+		t.Errorf("Expected %d members, got %v", len(fixtureArtists[0].Members), members)
+	}
+	concerts := artist["concerts"].([]interface{})
+	if len(concerts) != 3 {
+		t.Fatalf("Expected 3 concerts in Japan, got %v", concerts)
+	}
+	first := concerts[0].(map[string]interface{})
+	if city := first["location"].(map[string]interface{})["city"]; city == "" {
+		t.Errorf("Expected concerts to resolve their location, got %v", first)
+	}
+}
+
+func TestGraphQLKeepsFieldOrder(t *testing.T) {
+	rr := httptest.NewRecorder()
+	GraphQLHandler(rr, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{artist(id:2){name id title:firstAlbum}}`), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `{"name":"SOJA","id":2,"title":`) {
+		t.Errorf("Expected fields in the order of the query, got %s", body)
+	}
+}
+
+func TestGraphQLVariablesAndPagination(t *testing.T) {
+	query := `query Berlin($country: String!, $limit: Int = 1) {
+		concerts(country: $country, first: $limit) { date artist { name } }
+		locations(country: $country) { slug artists { id } }
+	}`
+	_, data, errs := postGraphQL(t, GraphQLRequest{Query: query, Variables: map[string]interface{}{"country": "Germany"}})
+	if len(errs) > 0 {
+		t.Fatalf("Expected no errors, got %+v", errs)
+	}
+	if concerts := data["concerts"].([]interface{}); len(concerts) != 1 {
+		t.Errorf("Expected first to limit concerts to 1, got %v", concerts)
+	}
+	locations := data["locations"].([]interface{})
+	if len(locations) != 1 {
+		t.Fatalf("Expected only Berlin, got %v", locations)
+	}
+	if artists := locations[0].(map[string]interface{})["artists"].([]interface{}); len(artists) != 3 {
+		t.Errorf("Expected 3 artists to have played Berlin, got %v", artists)
+	}
+
+	_, _, errs = postGraphQL(t, GraphQLRequest{Query: query})
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "$country") {
+		t.Errorf("Expected a missing variable error, got %+v", errs)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{"syntax", `{ artist(id: 1) { name }`, "expected"},
+		{"unknown field", `{ artist(id: 1) { genre } }`, `cannot query field "genre"`},
+		{"missing argument", `{ artist { name } }`, `argument "id"`},
+		{"missing selection", `{ artist(id: 1) }`, "selection of subfields"},
+		{"mutation", `mutation { artist(id: 1) { name } }`, "mutation"},
+		{"depth", `{ artist(id: 1) { concerts { artist { concerts { artist { concerts { artist { name } } } } } } } }`, "depth"},
+		{"complexity", `{ artists(first: 100) { concerts(first: 100) { artist { name id image } } } }`, "complexity"},
+		{"nested values", `{ artist(id: [[[[[[[[1]]]]]]]]) { name } }`, "depth"},
+		{"negative first", `{ artists(first: -1) { name } }`, "must not be negative"},
+		{"negative offset", `{ artists(offset: -5) { name } }`, "must not be negative"},
+		{"page size", `{ artists(first: 101) { name } }`, "must not exceed 100"},
+		{"32-bit int", `{ artists(first: 2147483648) { name } }`, "32 bits"},
+	}
+	for _, tt := range tests {
+		status, data, errs := postGraphQL(t, GraphQLRequest{Query: tt.query})
+		if status != http.StatusOK {
+			t.Errorf("%s: expected status OK; got %v", tt.name, status)
+		}
+		if data != nil {
+			t.Errorf("%s: expected no data, got %v", tt.name, data)
+		}
+		if len(errs) == 0 || !strings.Contains(errs[0].Message, tt.message) {
+			t.Errorf("%s: expected an error mentioning %q, got %+v", tt.name, tt.message, errs)
+		}
+	}
+}
+
+func TestGraphQLFieldErrorHasPath(t *testing.T) {
+	_, data, errs := postGraphQL(t, GraphQLRequest{Query: `{ artist(id: 3) { name concerts(from: "someday") { date } } }`})
+	if len(errs) != 1 {
+		t.Fatalf("Expected one error, got %+v", errs)
+	}
+	if len(errs[0].Path) != 2 || errs[0].Path[0] != "artist" || errs[0].Path[1] != "concerts" {
+		t.Errorf("Expected the error path [artist concerts], got %v", errs[0].Path)
+	}
+	if len(errs[0].Locations) != 1 || errs[0].Locations[0].Line != 1 {
+		t.Errorf("Expected the error location, got %v", errs[0].Locations)
+	}
+	if artist := data["artist"].(map[string]interface{}); artist["name"] != "Pink Floyd" || artist["concerts"] != nil {
+		t.Errorf("Expected partial data with a null field, got %v", artist)
+	}
+}
+
+func TestGraphQLBadRequest(t *testing.T) {
+	rr := httptest.NewRecorder()
+	GraphQLHandler(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader("{")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	body := `{"query": "{ artists { name } }", "operationName": "` + strings.Repeat("a", gqlMaxBodyBytes) + `"}`
+	GraphQLHandler(rr, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status Request Entity Too Large; got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	GraphQLHandler(rr, httptest.NewRequest("DELETE", "/graphql", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status Method Not Allowed; got %v", rr.Code)
+	}
+}
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`query Q($id: Int! = 3) { a: artist(id: $id) { name, members(first: 2) { name } } __typename }`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	op := doc.Operations[0]
+	if op.Name != "Q" || len(op.Variables) != 1 || !op.Variables[0].NonNull || op.Variables[0].Default != int64(3) {
+		t.Errorf("Unexpected operation %+v", op)
+	}
+	field := op.Selections[0]
+	if field.ResponseKey() != "a" || field.Name != "artist" || field.Args[0].Value != gqlVariable("id") {
+		t.Errorf("Unexpected field %+v", field)
+	}
+	if len(field.Selections) != 2 || op.Selections[1].Name != "__typename" {
+		t.Errorf("Unexpected selections %+v", op.Selections)
+	}
+
+	if _, err := parseGraphQL(`{ artist(id: "1 }`); err == nil {
+		t.Errorf("Expected an error for an unterminated string")
+	}
+}
+
+func TestCoerceIntRange(t *testing.T) {
+	for _, value := range []interface{}{int64(math.MaxInt32 + 1), int64(math.MinInt32 - 1), float64(1 << 40), 1.5} {
+		if _, err := coerceScalar(value, "Int"); err == nil {
+			t.Errorf("Expected %v to be refused as an Int", value)
+		}
+	}
+	if n, err := coerceScalar(float64(math.MinInt32), "Int"); err != nil || n != math.MinInt32 {
+		t.Errorf("Expected the smallest 32-bit integer, got %v (%v)", n, err)
+	}
+}
+
+func TestEstimateComplexitySaturates(t *testing.T) {
+	if n := saturatingMul(math.MaxInt/2, 3); n != math.MaxInt {
+		t.Errorf("Expected the product to saturate, got %d", n)
+	}
+	if n := saturatingAdd(math.MaxInt, 1); n != math.MaxInt {
+		t.Errorf("Expected the sum to saturate, got %d", n)
+	}
+
+	doc, err := parseGraphQL(`{ artists(first: 100) { locations(first: 100) { artists(first: 100) { locations(first: 100) { artists(first: 100) { locations(first: 100) { name } } } } } } }`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c := estimateComplexity("Query", doc.Operations[0].Selections, nil); c <= gqlMaxComplexity {
+		t.Errorf("Expected the complexity to exceed the maximum, got %d", c)
+	}
+}
+
+func TestGraphQLDefaultPageSize(t *testing.T) {
+	// More artists and concerts than a page holds
+	var artists []api.Artist
+	var relations []api.Relation
+	for id := 1; id <= 3*gqlDefaultListSize; id++ {
+		artists = append(artists, fixtureArtist(id, fmt.Sprintf("Band %d", id), 2000, "01-01-2001", "Singer"))
+		dates := map[string][]string{}
+		for day := 1; day <= 2*gqlDefaultListSize; day++ {
+			dates[fmt.Sprintf("city_%d-country", day)] = []string{fmt.Sprintf("%02d-01-2020", day)}
+		}
+		relations = append(relations, api.Relation{ID: id, DatesLocations: dates})
+	}
+	data := newGQLData(artists, relations)
+
+	query := `{ artists { name concerts { date artist { name } } } }`
+	doc, err := parseGraphQL(query)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c := estimateComplexity("Query", doc.Operations[0].Selections, nil); c > gqlMaxComplexity {
+		t.Fatalf("Expected the query to be accepted, got a complexity of %d", c)
+	}
+
+	resp := executeGraphQL(GraphQLRequest{Query: query}, data)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", resp.Errors)
+	}
+	body, _ := json.Marshal(resp.Data)
+	var result struct {
+		Artists []struct {
+			Concerts []interface{} `json:"concerts"`
+		} `json:"artists"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Could not decode result: %v", err)
+	}
+	if len(result.Artists) != gqlDefaultListSize {
+		t.Fatalf("Expected a page of %d artists without first, got %d", gqlDefaultListSize, len(result.Artists))
+	}
+	for _, a := range result.Artists {
+		if len(a.Concerts) != gqlDefaultListSize {
+			t.Errorf("Expected a page of %d concerts without first, got %d", gqlDefaultListSize, len(a.Concerts))
+		}
+	}
+
+	resp = executeGraphQL(GraphQLRequest{Query: `{ artists(first: 25) { name } }`}, data)
+	body, _ = json.Marshal(resp.Data)
+	if err := json.Unmarshal(body, &result); err != nil || len(result.Artists) != 25 {
+		t.Errorf("Expected first to override the default page size, got %d artists (%v)", len(result.Artists), err)
+	}
+}
@@ -4,21 +4,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
-	"text/template"
-	"time"
 
 	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
 )
 
 type TemplateData struct {
-	Artists   []api.Artist
-	Query     string
-	NoResults bool
+	AccountNav
+	Artists     []ArtistResult
+	Query       string
+	NoResults   bool
+	Suggestions []string
+	Popular     []ArtistResult
 }
 
 type ArtistDetailData struct {
@@ -26,82 +28,29 @@ type ArtistDetailData struct {
 	Location api.Location
 	Date     api.Date
 	Relation api.Relation
+	// Stops are the geocoded concerts in chronological order, for the map
+	Stops     []TourStop
+	TourStats TourStats
+	Similar   []SimilarArtist
+	CoBills   []CoBill
+	// SharedMembers are the members who also play in other bands
+	SharedMembers []Member
+	Favorite      bool
+	CSRF          string
 }
 
-var (
-	artistCache        []api.Artist
-	locationCache      []api.Location
-	dateCache          []api.Date
-	relationCache      []api.Relation
-	cacheTime          time.Time
-	cacheMutex         sync.RWMutex
-	isCacheInitialized bool
-)
-
-const cacheDuration = 10 * time.Minute
-
-func initCache() {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	if !isCacheInitialized {
-		updateCache()
-		isCacheInitialized = true
-	}
-}
-
-func updateCache() {
-	var wg sync.WaitGroup
-	wg.Add(4)
-
-	go func() {
-		defer wg.Done()
-		artists, err := api.GetArtists()
-		if err == nil {
-			artistCache = artists
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		locations, err := api.GetLocations()
-		if err == nil {
-			locationCache = locations
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		dates, err := api.GetDates()
-		if err == nil {
-			dateCache = dates
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		relations, err := api.GetRelations()
-		if err == nil {
-			relationCache = relations
-		}
-	}()
-
-	wg.Wait()
-	cacheTime = time.Now()
-}
-
-func getCachedData() ([]api.Artist, []api.Location, []api.Date, []api.Relation) {
-	cacheMutex.RLock()
-	defer cacheMutex.RUnlock()
-
-	if time.Since(cacheTime) > cacheDuration {
-		go updateCache()
-	}
+// templatePartials define the templates shared by several pages, such as the artist card
+var templatePartials = []string{"templates/card.html", "templates/account_nav.html"}
 
-	return artistCache, locationCache, dateCache, relationCache
+// templateFuncs are the helper functions available to page templates
+var templateFuncs = template.FuncMap{
+	"location": formatLocation,
+	"join":     strings.Join,
+	"slug":     memberSlug,
+	"card":     artistCard,
 }
 
-// ErrorHandler handles error responses and templates
+// ErrorHandler renders the error page with the given status code
 func ErrorHandler(w http.ResponseWriter, message string, statusCode int, logError, showStatusCode bool) {
 
 	if w.Header().Get("Content-Type") != "" {
@@ -146,6 +95,7 @@ func ErrorHandler(w http.ResponseWriter, message string, statusCode int, logErro
 		return
 	}
 
+	w.WriteHeader(statusCode)
 	_, err = buf.WriteTo(w)
 	if err != nil {
 		if logError {
@@ -159,30 +109,41 @@ func ErrorHandler(w http.ResponseWriter, message string, statusCode int, logErro
 	}
 }
 
-// ServeArtists handles the /artists route
+// ServeArtists handles the /artists route. The results are served as HTML, JSON or
+// CSV depending on the Accept header or the format parameter.
 func ServeArtists(w http.ResponseWriter, r *http.Request) {
-	initCache()
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
 	query := r.URL.Query().Get("query")
 
-	artists, _, _, _ := getCachedData()
+	// Questions like "who played in Germany in 2019" are answered by the concerts page
+	if place, year, ok := parsePlayedIn(query); ok && format == formatHTML {
+		http.Redirect(w, r, playedInURL(place, year), http.StatusFound)
+		return
+	}
 
-	for i := range artists {
-		// Format the URL with the value of i
-		url := fmt.Sprintf("https://groupietrackers.herokuapp.com/api/locations/%d", i+1)
+	initCache()
 
-		// Fetch artist locations using the formatted URL
-		locations, err := FetchArtistLocations(url)
-		if err == nil {
-			artists[i].Locations = strings.Join(locations, ", ")
-		} else {
-			log.Printf("Error fetching location for artist %d: %v", artists[i].ID, err)
-		}
-	}
+	artists, locations, _, relations := getCachedData()
+	artists = withLocationNames(artists, locations)
 
-	filteredArtists := filterArtists(artists, query)
+	filteredArtists := searchArtists(artists, relations, query)
 
-	if len(filteredArtists) == 0 && query != "" {
-		ErrorHandler(w, "No Result Found for this search.", http.StatusNotFound, false, false)
+	switch format {
+	case formatJSON:
+		if filteredArtists == nil {
+			filteredArtists = []ArtistResult{}
+		}
+		writeJSON(w, http.StatusOK, filteredArtists, nil)
+		return
+	case formatCSV:
+		results := make([]api.Artist, len(filteredArtists))
+		for i, result := range filteredArtists {
+			results[i] = result.Artist
+		}
+		writeCSV(w, "", artistCSVHeader, artistCSVRows(results))
 		return
 	}
 
@@ -192,270 +153,108 @@ func ServeArtists(w http.ResponseWriter, r *http.Request) {
 		NoResults: len(filteredArtists) == 0 && query != "",
 	}
 
-	tmpl, err := template.ParseFiles("templates/artists.html")
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		ErrorHandler(w, "An unexpected error occurred. Please try again later.", http.StatusInternalServerError, true, true)
-		return
-	}
-
-	// Use a buffer to render the template
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, data)
-	if err != nil {
-		log.Printf("Error executing template: %v", err)
-		ErrorHandler(w, "We encountered an issue while rendering the page. Please try again later.", http.StatusInternalServerError, true, true)
-		return
-	}
-
-	// Write the rendered template to the response
-	_, err = buf.WriteTo(w)
-	if err != nil {
-		if strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "connection reset by peer") {
-			log.Println("Client disconnected before response was fully sent")
-		} else {
-			log.Printf("Error writing response: %v", err)
-		}
-	}
-}
-
-// filterArtists filters the list of artists based on the search query
-func filterArtists(artists []api.Artist, query string) []api.Artist {
-	if query == "" {
-		return artists
+	// Keep the search context and offer alternatives instead of an error page
+	if data.NoResults {
+		data.Suggestions = suggestAlternatives(artists, query, maxSuggestions)
+		data.Popular = popularArtists(artists, relations, maxPopularArtists)
 	}
 
-	query = strings.ToLower(query)
-	var result []api.Artist
-
-	for _, a := range artists {
-		// Artist/band name matches
-		if strings.Contains(strings.ToLower(a.Name), query) {
-			result = append(result, a)
-			continue
-		}
-
-		// Members
-		for _, member := range a.Members {
-			if strings.Contains(strings.ToLower(member), query) {
-				result = append(result, a)
-				break
-			}
-		}
-
-		// First album dates
-		if strings.Contains(strings.ToLower(a.FirstAlbum), query) {
-			result = append(result, a)
-			continue
-		}
-
-		// creation dates
-		if strings.Contains(strconv.Itoa(a.CreationDate), query) {
-			result = append(result, a)
-			continue
-		}
+	ids := favoriteIDs(r)
+	markFavorites(data.Artists, ids)
+	markFavorites(data.Popular, ids)
+	data.AccountNav = accountNav(w, r)
 
-		// locations
-		if strings.Contains(strings.ToLower(a.Locations), strings.ToLower(query)) {
-			result = append(result, a)
-			continue
-		}
-	}
-	return result
+	renderTemplate(w, http.StatusOK, "templates/artists.html", data)
 }
 
+// GetSearchSuggestionsHandler handles the /search-suggestions route used by the search bar,
+// returning suggestions formatted as "value - category"
 func GetSearchSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
 	initCache()
+	w.Header().Set("Content-Type", "application/json")
 	query := r.URL.Query().Get("q")
 	if query == " " {
 		json.NewEncoder(w).Encode([]string{})
 		return
 	}
-	suggestions := []string{}
-	artists, _, _, _ := getCachedData()
-
-	for _, artist := range artists {
-		// Artist/band name
-		if strings.Contains(strings.ToLower(artist.Name), strings.ToLower(query)) {
-			suggestions = append(suggestions, fmt.Sprintf("%s - artist/band", artist.Name))
-		}
-
-		// Members
-		for _, member := range artist.Members {
-			if strings.Contains(strings.ToLower(member), strings.ToLower(query)) {
-				suggestions = append(suggestions, fmt.Sprintf("%s - member", member))
-			}
-		}
-
-		// First album date
-		if strings.Contains(strings.ToLower(artist.FirstAlbum), strings.ToLower(query)) {
-			suggestions = append(suggestions, fmt.Sprintf("%s - first album date", artist.FirstAlbum))
-		}
-
-		// Creation date
-		if strings.Contains(strconv.Itoa(artist.CreationDate), query) {
-			suggestions = append(suggestions, fmt.Sprintf("%d - creation date", artist.CreationDate))
-		}
-
-		// Locations
-		locations := strings.Split(artist.Locations, ", ")
-		for _, loc := range locations {
-			if strings.Contains(strings.ToLower(loc), strings.ToLower(query)) {
-				suggestions = append(suggestions, fmt.Sprintf("%s - location", loc))
-			}
-		}
+	artists, locations, _, _ := getCachedData()
 
+	suggestions := []string{}
+	for _, s := range searchSuggestions(withLocationNames(artists, locations), query) {
+		suggestions = append(suggestions, fmt.Sprintf("%s - %s", s.Value, s.Category))
 	}
 
 	json.NewEncoder(w).Encode(suggestions)
 }
-// Serve artist details page
-func ServeArtistDetails(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/artist/" {
-		ErrorHandler(w, "oops! page not found", http.StatusNotFound, true, true)
-		return
-	}
 
-	idStr := r.URL.Path[len("/artist/"):]
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		ErrorHandler(w, "Invalid artist ID", http.StatusBadRequest, true, true)
-		return
-	}
-
-	artist, location, date, relation, err := api.GetArtistByID(id)
-	if err != nil {
-		log.Printf("Error retrieving artist by ID %v: %s", id, err)
-		ErrorHandler(w, "Ooops!\n We ran into an issue while fetching Artists,\n Please try again later.", http.StatusInternalServerError, false, false)
+// Serve artist details page, as HTML, JSON or CSV of its concerts
+func ServeArtistDetails(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r)
+	if !ok {
 		return
 	}
 
-	data := ArtistDetailData{
-		Artist:   *artist,
-		Location: *location,
-		Date:     *date,
-		Relation: *relation,
-	}
-
-	tmpl, err := template.ParseFiles("templates/artist_details.html")
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		ErrorHandler(w, "Unable to load artist details at this time. Please try again later.", http.StatusInternalServerError, false, false)
-		return
-	}
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		log.Printf("Error executing template: %v", err)
-		ErrorHandler(w, "Error rendering artist details. Please try again later.", http.StatusInternalServerError, false, false)
+	if r.URL.Path == "/artist/" {
+		negotiatedError(w, format, "oops! page not found", http.StatusNotFound)
 		return
 	}
-}
 
-// GetArtistsHandler handles the /artists route
-func GetArtistsHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("query")
-	artists, err := api.GetArtists()
+	idStr := r.URL.Path[len("/artist/"):]
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Printf("Error fetching artists: %v", err)
-		ErrorHandler(w, "Unable to retrieve artist information at this time. Please try again later.", http.StatusInternalServerError, false, false)
+		negotiatedError(w, format, "Invalid artist ID", http.StatusBadRequest)
 		return
 	}
 
-	filteredArtists := filterArtists(artists, query)
-
-	if len(filteredArtists) == 0 {
-		ErrorHandler(w, "No artists found matching the search term.", http.StatusNotFound, false, false)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(filteredArtists); err != nil {
-		log.Printf("Error encoding artists data to JSON: %v", err)
-		ErrorHandler(w, "An error occurred while processing the artist data. Please try again later.", http.StatusInternalServerError, false, false)
-		return
-	}
-}
-
-// GetLocationsHandler handles the /locations route
-func GetLocationsHandler(w http.ResponseWriter, r *http.Request) {
 	initCache()
-	_, locations, _, _ := getCachedData()
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(locations); err != nil {
-		log.Printf("Error encoding locations data to JSON: %v", err)
-		ErrorHandler(w, "An error occurred while processing location data. Please try again later.", http.StatusInternalServerError, false, false)
+	artists, locations, dates, relations := getCachedData()
+	if artists == nil {
+		log.Printf("Artist %v requested before the cache was loaded", id)
+		negotiatedError(w, format, "Ooops!\n We ran into an issue while fetching Artists,\n Please try again later.", http.StatusInternalServerError)
 		return
 	}
-}
-
-// GetDatesHandler handles the /dates route
-func GetDatesHandler(w http.ResponseWriter, r *http.Request) {
-	initCache()
-	_, _, dates, _ := getCachedData()
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(dates); err != nil {
-		log.Printf("Error encoding dates data to JSON: %v", err)
-		ErrorHandler(w, "An error occurred while processing date information. Please try again later.", http.StatusInternalServerError, false, false)
+	artist, ok := findArtist(artists, id)
+	if !ok {
+		negotiatedError(w, format, "Artist not found", http.StatusNotFound)
 		return
 	}
-}
-
-// GetRelationsHandler handles the /relations route
-func GetRelationsHandler(w http.ResponseWriter, r *http.Request) {
-	initCache()
-	_, _, _, relations := getCachedData()
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(relations); err != nil {
-		log.Printf("Error encoding relations data to JSON: %v", err)
-		ErrorHandler(w, "An error occurred while processing relation data. Please try again later.", http.StatusInternalServerError, false, false)
+	switch format {
+	case formatJSON:
+		writeJSON(w, http.StatusOK, ArtistDetail{Artist: artist, Concerts: artistConcerts(relations, id), TourStats: getTourStats(id)}, nil)
 		return
-	}
-}
-
-func GetArtistByIDHandler(w http.ResponseWriter, r *http.Request) {
-	// Get artist ID from the URL path
-	idStr := r.URL.Path[len("/artists/"):]
-	artistID, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.Printf("Invalid artist ID: %v", err)
-		ErrorHandler(w, "Invalid artist ID provided. Please check and try again.", http.StatusBadRequest, true, true)
+	case formatCSV:
+		writeCSV(w, "", concertCSVHeader, concertCSVRows(artists, artistConcerts(relations, id)))
 		return
 	}
 
-	artist, location, date, relation, err := api.GetArtistByID(artistID)
-	if err != nil {
-		// Check if the artist was not found
-		if err.Error() == "artist not found" {
-			ErrorHandler(w, "Artist not found. Please check the ID and try again.", http.StatusNotFound, true, true)
-		} else {
-			log.Printf("Error fetching artist details with ID %d: %v", artistID, err)
-			ErrorHandler(w, "Unable to retrieve artist details at this time. Please try again later.", http.StatusInternalServerError, false, false)
+	data := ArtistDetailData{
+		Artist:        artist,
+		Stops:         buildTourStops(artistConcerts(relations, id)),
+		TourStats:     getTourStats(id),
+		Similar:       getSimilarArtists(id),
+		CoBills:       artistCoBills(getCoBills(), id),
+		SharedMembers: artistSharedMembers(getMembers(), artist),
+		Favorite:      indexOfInt(favoriteIDs(r), id) >= 0,
+	}
+	_, data.CSRF = pageSession(w, r)
+	for _, l := range locations {
+		if l.ID == id {
+			data.Location = l
 		}
-		return
 	}
-
-	// Create a response combining artist, location, date, and relation data
-	response := struct {
-		Artist   *api.Artist   `json:"artist"`
-		Location *api.Location `json:"location"`
-		Date     *api.Date     `json:"date"`
-		Relation *api.Relation `json:"relation"`
-	}{
-		Artist:   artist,
-		Location: location,
-		Date:     date,
-		Relation: relation,
+	for _, d := range dates {
+		if d.ID == id {
+			data.Date = d
+		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response for artist ID %d: %v", artistID, err)
-		ErrorHandler(w, "An error occurred while processing the response. Please try again later.", http.StatusInternalServerError, false, false)
-		return
+	for _, rel := range relations {
+		if rel.ID == id {
+			data.Relation = rel
+		}
 	}
+
+	renderTemplate(w, http.StatusOK, "templates/artist_details.html", data)
 }
 
 // Serve About Page
@@ -478,17 +277,30 @@ func AboutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Fetch artist locations from the provided URL
-func FetchArtistLocations(locationsURL string) ([]string, error) {
-	body, err := api.FetchData(locationsURL)
+// renderTemplate renders the template file into a buffer and writes it out with the
+// given status code, falling back to the error page when the template cannot be rendered
+func renderTemplate(w http.ResponseWriter, statusCode int, file string, data interface{}) {
+	tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs).ParseFiles(append([]string{file}, templatePartials...)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch location data: %v", err)
+		log.Printf("Error parsing template %s: %v", file, err)
+		ErrorHandler(w, "An unexpected error occurred. Please try again later.", http.StatusInternalServerError, true, true)
+		return
 	}
 
-	var locationData api.Location
-	if err := json.Unmarshal(body, &locationData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal location data: %v", err)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error executing template %s: %v", file, err)
+		ErrorHandler(w, "We encountered an issue while rendering the page. Please try again later.", http.StatusInternalServerError, true, true)
+		return
 	}
 
-	return locationData.Locations, nil
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if _, err := buf.WriteTo(w); err != nil {
+		if strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "connection reset by peer") {
+			log.Println("Client disconnected before response was fully sent")
+		} else {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
 }
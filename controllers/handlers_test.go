@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -13,7 +14,21 @@ func TestMain(m *testing.M) {
 	if err := os.Chdir(".."); err != nil {
 		panic(err)
 	}
-	os.Exit(m.Run())
+
+	// Keep the stores out of data/, so refreshes never notify the real webhooks
+	dir, err := os.MkdirTemp("", "groupie-tracker-test")
+	if err != nil {
+		panic(err)
+	}
+	favorites = newFavoriteStore(filepath.Join(dir, "favorites.json"))
+	savedSearches = newSavedSearchStore(filepath.Join(dir, "searches.json"))
+	accounts = newAccountStore(filepath.Join(dir, "accounts.db"))
+
+	seedTestCache()
+	code := m.Run()
+	accounts.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func TestServeArtists(t *testing.T) {
@@ -35,6 +50,25 @@ func TestServeArtists(t *testing.T) {
 	}
 }
 
+func TestServeArtistsEscapesQuery(t *testing.T) {
+	req, err := http.NewRequest("GET", "/?query=%22%3E%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ServeArtists)
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "<script>alert(1)") {
+		t.Errorf("Expected the query to be escaped")
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(1)") {
+		t.Errorf("Expected the escaped query in the page")
+	}
+}
+
 func TestServeArtistDetails(t *testing.T) {
 	req, err := http.NewRequest("GET", "/artist/1", nil)
 	if err != nil {
@@ -55,7 +89,7 @@ func TestServeArtistDetails(t *testing.T) {
 }
 
 func TestGetArtistsHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/artists?query=Queen", nil)
+	req, err := http.NewRequest("GET", "/api/v1/artists?query=Queen", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -74,7 +108,7 @@ func TestGetArtistsHandler(t *testing.T) {
 }
 
 func TestGetLocationsHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/locations", nil)
+	req, err := http.NewRequest("GET", "/api/v1/locations", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -93,7 +127,7 @@ func TestGetLocationsHandler(t *testing.T) {
 }
 
 func TestGetDatesHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/dates", nil)
+	req, err := http.NewRequest("GET", "/api/v1/dates", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -112,7 +146,7 @@ func TestGetDatesHandler(t *testing.T) {
 }
 
 func TestGetRelationsHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/relations", nil)
+	req, err := http.NewRequest("GET", "/api/v1/relations", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -131,7 +165,7 @@ func TestGetRelationsHandler(t *testing.T) {
 }
 
 func TestGetArtistByIDHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/artists/1", nil)
+	req, err := http.NewRequest("GET", "/api/v1/artists/1", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -197,8 +231,8 @@ func TestServeArtistDetailsInvalidID(t *testing.T) {
 	handler := http.HandlerFunc(ServeArtistDetails)
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected statusOK; got %v", rr.Code)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
 	}
 
 	expectedErrorMessage := "Invalid artist ID"
@@ -211,8 +245,8 @@ func TestErrorHandler(t *testing.T) {
 	rr := httptest.NewRecorder()
 	ErrorHandler(rr, "Test error", http.StatusNotFound, true, true)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status OK; got %v", rr.Code)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
 	}
 
 	if !strings.Contains(rr.Body.String(), "Test error") {
@@ -221,7 +255,7 @@ func TestErrorHandler(t *testing.T) {
 }
 
 func TestGetArtistsHandlerWithFilter(t *testing.T) {
-	req, err := http.NewRequest("GET", "/artists?query=Queen", nil)
+	req, err := http.NewRequest("GET", "/api/v1/artists?query=Queen", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
@@ -242,3 +276,37 @@ func TestGetArtistsHandlerWithFilter(t *testing.T) {
 		t.Errorf("Expected filtered response not to contain 'The Beatles'")
 	}
 }
+
+func TestServeArtistsNoResults(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?query=Quen", nil)
+	rr := httptest.NewRecorder()
+	ServeArtists(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK; got %v", rr.Code)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `value="Quen"`) {
+		t.Errorf("Expected the search bar to keep the query")
+	}
+	if !strings.Contains(body, "Did you mean") || !strings.Contains(body, "/?query=Queen") {
+		t.Errorf("Expected a suggestion for 'Queen'")
+	}
+	if !strings.Contains(body, "Popular artists") {
+		t.Errorf("Expected popular artists to be suggested")
+	}
+}
+
+func TestGetArtistsHandlerNoResults(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v1/artists?query=zzzzzz", nil)
+	rr := httptest.NewRecorder()
+	GetArtistsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK; got %v", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"data":[]`) {
+		t.Errorf("Expected an empty JSON array, got %s", rr.Body.String())
+	}
+}
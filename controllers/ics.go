@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// icsMaxLineOctets is the longest content line RFC 5545 allows before folding
+const icsMaxLineOctets = 75
+
+// icsEscape escapes a TEXT property value as described in RFC 5545 section 3.3.11
+func icsEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// icsFold splits a content line into lines of at most 75 octets, continuing
+// each with a space, without cutting a UTF-8 sequence in half
+func icsFold(line string) string {
+	var b strings.Builder
+	limit := icsMaxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation counts towards its length
+		limit = icsMaxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// icsUID identifies a concert across feeds and refreshes
+func icsUID(c Concert) string {
+	return fmt.Sprintf("%d-%s-%s@groupie-tracker", c.ArtistID, c.Location, c.Time.Format("20060102"))
+}
+
+// buildCalendar renders the concerts as all-day events of an iCalendar feed.
+// stamp is the DTSTAMP of every event: the time the data was fetched.
+func buildCalendar(name string, artists []api.Artist, concerts []Concert, stamp time.Time) []byte {
+	names := make(map[int]string, len(artists))
+	for _, a := range artists {
+		names[a.ID] = a.Name
+	}
+
+	var buf bytes.Buffer
+	line := func(property, value string) {
+		buf.WriteString(icsFold(property + ":" + value))
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Groupie Tracker//Concerts//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", icsEscape(name))
+	for _, c := range concerts {
+		place := formatLocation(c.Location)
+		line("BEGIN", "VEVENT")
+		line("UID", icsUID(c))
+		line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE", c.Time.Format("20060102"))
+		line("DTEND;VALUE=DATE", c.Time.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", icsEscape(names[c.ArtistID]+" in "+place))
+		line("LOCATION", icsEscape(place))
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// writeCalendar writes an iCalendar feed
+func writeCalendar(w http.ResponseWriter, filename string, calendar []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	if _, err := w.Write(calendar); err != nil {
+		log.Printf("Error writing calendar: %v", err)
+	}
+}
+
+// ArtistCalendarHandler handles the /artist/{id}/concerts.ics route
+func ArtistCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/artist/"), "/concerts.ics")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid artist ID", http.StatusBadRequest)
+		return
+	}
+
+	initCache()
+	artists, _, _, relations := getCachedData()
+	artist, ok := findArtist(artists, id)
+	if !ok {
+		http.Error(w, "artist not found", http.StatusNotFound)
+		return
+	}
+
+	calendar := buildCalendar(artist.Name+" concerts", artists, artistConcerts(relations, id), cachedAt())
+	writeCalendar(w, "concerts.ics", calendar)
+}
+
+// LocationCalendarHandler handles the /location/{slug}/concerts.ics route. The slug
+// is a location key of the API, such as "osaka-japan", or a country such as "japan".
+func LocationCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	slug := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/location/"), "/concerts.ics"))
+
+	initCache()
+	artists, _, _, relations := getCachedData()
+	var concerts []Concert
+	for _, c := range buildConcerts(relations) {
+		if atLocation(c, slug) {
+			concerts = append(concerts, c)
+		}
+	}
+	if concerts == nil {
+		http.Error(w, "location not found", http.StatusNotFound)
+		return
+	}
+
+	name := formatLocation(slug)
+	if !strings.Contains(slug, "-") {
+		name = formatCountry(strings.ReplaceAll(slug, "_", " "))
+	}
+	calendar := buildCalendar("Concerts in "+name, artists, concerts, cachedAt())
+	writeCalendar(w, "concerts.ics", calendar)
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unfoldCalendar checks the line limits of a feed and returns its unfolded content lines
+func unfoldCalendar(t *testing.T, body string) []string {
+	t.Helper()
+	if !strings.HasSuffix(body, "\r\n") {
+		t.Errorf("Expected lines to end with CRLF")
+	}
+	var lines []string
+	for _, raw := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		if len(raw) > icsMaxLineOctets {
+			t.Errorf("Line longer than %d octets: %q", icsMaxLineOctets, raw)
+		}
+		if strings.HasPrefix(raw, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		lines = append(lines, raw)
+	}
+	return lines
+}
+
+// calendarEvents groups the properties of every VEVENT
+func calendarEvents(lines []string) []map[string]string {
+	var events []map[string]string
+	var current map[string]string
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			current = map[string]string{}
+		case "END:VEVENT":
+			events = append(events, current)
+			current = nil
+		default:
+			if current != nil {
+				name, value, _ := strings.Cut(line, ":")
+				current[name] = value
+			}
+		}
+	}
+	return events
+}
+
+func TestICSEscape(t *testing.T) {
+	got := icsEscape("Rock; Roll, \\ Live\nTonight")
+	expected := `Rock\; Roll\, \\ Live\nTonight`
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 100)
+	folded := icsFold(line)
+	lines := unfoldCalendar(t, folded)
+	if len(lines) != 1 || lines[0] != line {
+		t.Errorf("Expected folding to round-trip, got %q", lines)
+	}
+	for _, part := range strings.Split(folded, "\r\n ") {
+		if !strings.HasPrefix(strings.TrimPrefix(part, "SUMMARY:"), "é") {
+			t.Errorf("Expected folding not to split UTF-8 sequences, got %q", part)
+		}
+	}
+}
+
+func TestArtistCalendar(t *testing.T) {
+	rr := serveAPI(ArtistCalendarHandler, "GET", "/artist/3/concerts.ics")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Expected a calendar, got %q", ct)
+	}
+
+	lines := unfoldCalendar(t, rr.Body.String())
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("Expected a VCALENDAR, got %q ... %q", lines[0], lines[len(lines)-1])
+	}
+	events := calendarEvents(lines)
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 Pink Floyd concerts, got %d", len(events))
+	}
+
+	first := events[0]
+	if first["DTSTART;VALUE=DATE"] != "20190414" || first["DTEND;VALUE=DATE"] != "20190415" {
+		t.Errorf("Expected an all-day event on 14 April 2019, got %v", first)
+	}
+	if first["SUMMARY"] != `Pink Floyd in London\, UK` || first["LOCATION"] != `London\, UK` {
+		t.Errorf("Expected escaped summary and location, got %v", first)
+	}
+
+	uids := map[string]bool{}
+	for _, e := range events {
+		if uids[e["UID"]] {
+			t.Errorf("Duplicate UID %s", e["UID"])
+		}
+		uids[e["UID"]] = true
+	}
+
+	// UIDs must not change between requests, so calendar apps update events in place
+	again := calendarEvents(unfoldCalendar(t, serveAPI(ArtistCalendarHandler, "GET", "/artist/3/concerts.ics").Body.String()))
+	if again[0]["UID"] != first["UID"] {
+		t.Errorf("Expected stable UIDs, got %s and %s", first["UID"], again[0]["UID"])
+	}
+
+	if rr := serveAPI(ArtistCalendarHandler, "GET", "/artist/999/concerts.ics"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+}
+
+func TestLocationCalendar(t *testing.T) {
+	rr := serveAPI(LocationCalendarHandler, "GET", "/location/berlin-germany/concerts.ics")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	lines := unfoldCalendar(t, rr.Body.String())
+	events := calendarEvents(lines)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 concerts in Berlin, got %d", len(events))
+	}
+	if events[1]["SUMMARY"] != `Genesis in Berlin\, Germany` || events[2]["SUMMARY"] != `Phil Collins in Berlin\, Germany` {
+		t.Errorf("Expected concerts of every artist, got %v", events)
+	}
+
+	if rr := serveAPI(LocationCalendarHandler, "GET", "/location/atlantis/concerts.ics"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+}
+
+func TestBuildCalendarStamp(t *testing.T) {
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	concerts := artistConcerts(fixtureRelations, 1)
+	events := calendarEvents(unfoldCalendar(t, string(buildCalendar("Queen concerts", fixtureArtists, concerts, stamp))))
+	if len(events) != len(concerts) || events[0]["DTSTAMP"] != "20240102T030405Z" {
+		t.Errorf("Expected every event to be stamped, got %v", events)
+	}
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// DatasetCounts holds the number of records of each API dataset
+type DatasetCounts struct {
+	Artists   int `json:"artists"`
+	Locations int `json:"locations"`
+	Dates     int `json:"dates"`
+	Relations int `json:"relations"`
+}
+
+// IntegrityIssue describes an inconsistency in the records of a single artist
+type IntegrityIssue struct {
+	ArtistID int    `json:"artistId"`
+	Message  string `json:"message"`
+}
+
+// IntegrityReport is the result of cross-checking the four API datasets.
+// Missing lists hold artist IDs without a record in that dataset, Orphaned
+// lists hold record IDs without a matching artist. EmptyDatasets and FetchErrors
+// report data that could not be loaded at all.
+type IntegrityReport struct {
+	CheckedAt         time.Time        `json:"checkedAt"`
+	OK                bool             `json:"ok"`
+	Counts            DatasetCounts    `json:"counts"`
+	CountMismatch     bool             `json:"countMismatch"`
+	DuplicateIDs      map[string][]int `json:"duplicateIds"`
+	MissingLocations  []int            `json:"missingLocations"`
+	MissingDates      []int            `json:"missingDates"`
+	MissingRelations  []int            `json:"missingRelations"`
+	OrphanedLocations []int            `json:"orphanedLocations"`
+	OrphanedDates     []int            `json:"orphanedDates"`
+	OrphanedRelations []int            `json:"orphanedRelations"`
+	Mismatches        []IntegrityIssue `json:"mismatches"`
+	EmptyDatasets     []string         `json:"emptyDatasets"`
+	FetchErrors       []string         `json:"fetchErrors"`
+}
+
+// checkIntegrity cross-checks the datasets, which are all expected to describe the
+// same artists keyed by ID
+func checkIntegrity(artists []api.Artist, locations []api.Location, dates []api.Date, relations []api.Relation) IntegrityReport {
+	report := IntegrityReport{
+		CheckedAt: time.Now(),
+		Counts: DatasetCounts{
+			Artists:   len(artists),
+			Locations: len(locations),
+			Dates:     len(dates),
+			Relations: len(relations),
+		},
+		DuplicateIDs:      map[string][]int{},
+		MissingLocations:  []int{},
+		MissingDates:      []int{},
+		MissingRelations:  []int{},
+		OrphanedLocations: []int{},
+		OrphanedDates:     []int{},
+		OrphanedRelations: []int{},
+		Mismatches:        []IntegrityIssue{},
+		EmptyDatasets:     []string{},
+		FetchErrors:       []string{},
+	}
+	c := report.Counts
+	for _, d := range []struct {
+		name  string
+		count int
+	}{{"artists", c.Artists}, {"locations", c.Locations}, {"dates", c.Dates}, {"relations", c.Relations}} {
+		if d.count == 0 {
+			report.EmptyDatasets = append(report.EmptyDatasets, d.name)
+		}
+	}
+	report.CountMismatch = c.Locations != c.Artists || c.Dates != c.Artists || c.Relations != c.Artists
+
+	artistIDs := make([]int, len(artists))
+	for i, a := range artists {
+		artistIDs[i] = a.ID
+	}
+	locationByID := make(map[int]api.Location, len(locations))
+	locationIDs := make([]int, len(locations))
+	for i, l := range locations {
+		locationByID[l.ID] = l
+		locationIDs[i] = l.ID
+	}
+	dateByID := make(map[int]api.Date, len(dates))
+	dateIDs := make([]int, len(dates))
+	for i, d := range dates {
+		dateByID[d.ID] = d
+		dateIDs[i] = d.ID
+	}
+	relationByID := make(map[int]api.Relation, len(relations))
+	relationIDs := make([]int, len(relations))
+	for i, r := range relations {
+		relationByID[r.ID] = r
+		relationIDs[i] = r.ID
+	}
+
+	for name, ids := range map[string][]int{"artists": artistIDs, "locations": locationIDs, "dates": dateIDs, "relations": relationIDs} {
+		if dup := duplicates(ids); len(dup) > 0 {
+			report.DuplicateIDs[name] = dup
+		}
+	}
+
+	artistSet := make(map[int]bool, len(artists))
+	for _, id := range artistIDs {
+		artistSet[id] = true
+		location, hasLocation := locationByID[id]
+		date, hasDate := dateByID[id]
+		relation, hasRelation := relationByID[id]
+		if !hasLocation {
+			report.MissingLocations = append(report.MissingLocations, id)
+		}
+		if !hasDate {
+			report.MissingDates = append(report.MissingDates, id)
+		}
+		if !hasRelation {
+			report.MissingRelations = append(report.MissingRelations, id)
+			continue
+		}
+
+		// The relation is the join of locations and dates, so both must agree with it
+		concerts := 0
+		for _, d := range relation.DatesLocations {
+			concerts += len(d)
+		}
+		if hasLocation && !sameLocations(location.Locations, relation.DatesLocations) {
+			report.Mismatches = append(report.Mismatches, IntegrityIssue{id, fmt.Sprintf("%d locations but %d locations in relations", len(location.Locations), len(relation.DatesLocations))})
+		}
+		if hasDate && len(date.Dates) != concerts {
+			report.Mismatches = append(report.Mismatches, IntegrityIssue{id, fmt.Sprintf("%d dates but %d concerts in relations", len(date.Dates), concerts)})
+		}
+	}
+
+	report.OrphanedLocations = orphans(locationIDs, artistSet)
+	report.OrphanedDates = orphans(dateIDs, artistSet)
+	report.OrphanedRelations = orphans(relationIDs, artistSet)
+
+	report.OK = !report.CountMismatch && len(report.DuplicateIDs) == 0 &&
+		len(report.MissingLocations)+len(report.MissingDates)+len(report.MissingRelations) == 0 &&
+		len(report.OrphanedLocations)+len(report.OrphanedDates)+len(report.OrphanedRelations) == 0 &&
+		len(report.Mismatches) == 0 && len(report.EmptyDatasets) == 0
+	return report
+}
+
+// sameLocations reports whether the location list names exactly the relation's locations
+func sameLocations(locations []string, datesLocations map[string][]string) bool {
+	seen := make(map[string]bool, len(locations))
+	for _, l := range locations {
+		if _, ok := datesLocations[l]; !ok {
+			return false
+		}
+		seen[l] = true
+	}
+	return len(seen) == len(datesLocations)
+}
+
+// duplicates returns the IDs appearing more than once, sorted
+func duplicates(ids []int) []int {
+	counts := make(map[int]int, len(ids))
+	var dup []int
+	for _, id := range ids {
+		counts[id]++
+		if counts[id] == 2 {
+			dup = append(dup, id)
+		}
+	}
+	sort.Ints(dup)
+	return dup
+}
+
+// orphans returns the IDs that do not belong to a known artist
+func orphans(ids []int, artists map[int]bool) []int {
+	result := []int{}
+	for _, id := range ids {
+		if !artists[id] {
+			result = append(result, id)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Problems lists the issues found, in a form suitable for logging
+func (r IntegrityReport) Problems() []string {
+	var problems []string
+	c := r.Counts
+	if r.CountMismatch {
+		problems = append(problems, fmt.Sprintf("record counts differ: %d artists, %d locations, %d dates, %d relations", c.Artists, c.Locations, c.Dates, c.Relations))
+	}
+	if len(r.EmptyDatasets) > 0 {
+		problems = append(problems, fmt.Sprintf("empty datasets: %v", r.EmptyDatasets))
+	}
+	for _, e := range r.FetchErrors {
+		problems = append(problems, "fetch error: "+e)
+	}
+	for dataset, ids := range r.DuplicateIDs {
+		problems = append(problems, fmt.Sprintf("duplicate IDs in %s: %v", dataset, ids))
+	}
+	lists := []struct {
+		format string
+		ids    []int
+	}{
+		{"artists missing from locations: %v", r.MissingLocations},
+		{"artists missing from dates: %v", r.MissingDates},
+		{"artists missing from relations: %v", r.MissingRelations},
+		{"orphaned locations: %v", r.OrphanedLocations},
+		{"orphaned dates: %v", r.OrphanedDates},
+		{"orphaned relations: %v", r.OrphanedRelations},
+	}
+	for _, l := range lists {
+		if len(l.ids) > 0 {
+			problems = append(problems, fmt.Sprintf(l.format, l.ids))
+		}
+	}
+	for _, m := range r.Mismatches {
+		problems = append(problems, fmt.Sprintf("artist %d: %s", m.ArtistID, m.Message))
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// Log writes the outcome of the check to the server log
+func (r IntegrityReport) Log() {
+	if r.OK {
+		log.Printf("Data integrity check passed for %d artists", r.Counts.Artists)
+		return
+	}
+	for _, p := range r.Problems() {
+		log.Printf("Data integrity: %s", p)
+	}
+}
+
+// recordFetchErrors fails the report of the last refresh with the datasets that
+// could not be fetched, which kept their previous value
+func recordFetchErrors(errs []error) {
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	integrityReport.FetchErrors = messages
+	integrityReport.OK = false
+}
+
+// IntegrityHandler handles the /admin/integrity route, returning the report of the last refresh
+func IntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	cacheMutex.RLock()
+	report := integrityReport
+	cacheMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding integrity report to JSON: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+func TestCheckIntegrityConsistentData(t *testing.T) {
+	report := checkIntegrity(fixtureArtists, fixtureLocations, fixtureDates, fixtureRelations)
+	if !report.OK {
+		t.Errorf("Expected fixtures to be consistent, got problems %v", report.Problems())
+	}
+}
+
+func TestCheckIntegrityDetectsProblems(t *testing.T) {
+	artists := []api.Artist{{ID: 1}, {ID: 2}}
+	locations := []api.Location{{ID: 1, Locations: []string{"osaka-japan", "paris-france"}}, {ID: 7}}
+	dates := []api.Date{{ID: 1, Dates: []string{"*01-01-2020"}}, {ID: 2}, {ID: 2}}
+	relations := []api.Relation{{ID: 1, DatesLocations: map[string][]string{"osaka-japan": {"01-01-2020", "02-01-2020"}}}}
+
+	report := checkIntegrity(artists, locations, dates, relations)
+	if report.OK {
+		t.Fatalf("Expected problems to be reported")
+	}
+	if !report.CountMismatch {
+		t.Errorf("Expected mismatched counts to be reported")
+	}
+	if len(report.MissingLocations) != 1 || report.MissingLocations[0] != 2 {
+		t.Errorf("Expected artist 2 to be missing from locations, got %v", report.MissingLocations)
+	}
+	if len(report.MissingRelations) != 1 || report.MissingRelations[0] != 2 {
+		t.Errorf("Expected artist 2 to be missing from relations, got %v", report.MissingRelations)
+	}
+	if len(report.OrphanedLocations) != 1 || report.OrphanedLocations[0] != 7 {
+		t.Errorf("Expected location 7 to be orphaned, got %v", report.OrphanedLocations)
+	}
+	if dup := report.DuplicateIDs["dates"]; len(dup) != 1 || dup[0] != 2 {
+		t.Errorf("Expected duplicate date ID 2, got %v", report.DuplicateIDs)
+	}
+	if len(report.Mismatches) != 2 {
+		t.Errorf("Expected location and date mismatches for artist 1, got %v", report.Mismatches)
+	}
+	if len(report.Problems()) == 0 {
+		t.Errorf("Expected problems to be listed")
+	}
+}
+
+func TestCheckIntegrityEmptyData(t *testing.T) {
+	report := checkIntegrity(nil, nil, nil, nil)
+	if report.OK {
+		t.Fatalf("Expected empty data to fail the check")
+	}
+	if len(report.EmptyDatasets) != 4 || report.EmptyDatasets[0] != "artists" {
+		t.Errorf("Expected the 4 datasets to be reported empty, got %v", report.EmptyDatasets)
+	}
+	if problems := report.Problems(); len(problems) != 1 || problems[0] != "empty datasets: [artists locations dates relations]" {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+}
+
+func TestRecordFetchErrors(t *testing.T) {
+	defer seedTestCache()
+	recordFetchErrors([]error{nil, errors.New("dates: connection refused"), nil, nil})
+
+	cacheMutex.RLock()
+	report := integrityReport
+	cacheMutex.RUnlock()
+	if report.OK || len(report.FetchErrors) != 1 || report.FetchErrors[0] != "dates: connection refused" {
+		t.Errorf("Expected the fetch error to fail the report, got %+v", report)
+	}
+	if problems := report.Problems(); len(problems) != 1 || !strings.Contains(problems[0], "connection refused") {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+}
+
+func TestIntegrityHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/integrity", nil)
+	rr := httptest.NewRecorder()
+	IntegrityHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK; got %v", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Expected a JSON response, got %s", rr.Header().Get("Content-Type"))
+	}
+
+	var report IntegrityReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Could not decode report: %v", err)
+	}
+	if report.Counts.Artists != len(fixtureArtists) || !report.OK {
+		t.Errorf("Expected a clean report for %d artists, got %+v", len(fixtureArtists), report)
+	}
+}
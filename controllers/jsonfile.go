@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// jsonFile keeps a value of type T in a JSON file. The file is read on first use and
+// rewritten on every change; callers hold their own lock around both.
+type jsonFile[T any] struct {
+	path   string
+	what   string
+	loaded bool
+	data   T
+	// reset fills in the zero parts of data, such as nil maps, after it is created
+	// and after it is loaded
+	reset func(*T)
+}
+
+// newJSONFile returns a store for the file at path. what names its contents in
+// error messages.
+func newJSONFile[T any](path, what string, reset func(*T)) jsonFile[T] {
+	f := jsonFile[T]{path: path, what: what, reset: reset}
+	f.reset(&f.data)
+	return f
+}
+
+// load reads the file once. A missing file is an empty store.
+func (f *jsonFile[T]) load() {
+	if f.loaded {
+		return
+	}
+	f.loaded = true
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &f.data)
+	}
+	if err != nil {
+		log.Printf("Error loading %s from %s: %v", f.what, f.path, err)
+	}
+	f.reset(&f.data)
+}
+
+// save writes the store to a temporary file renamed over the old one, so a crash
+// cannot leave a truncated file behind
+func (f *jsonFile[T]) save() error {
+	data, err := json.MarshalIndent(f.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestJSONFile(path string) jsonFile[map[string]int] {
+	return newJSONFile(path, "counts", func(counts *map[string]int) {
+		if *counts == nil {
+			*counts = map[string]int{}
+		}
+	})
+}
+
+func TestJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "counts.json")
+	f := newTestJSONFile(path)
+	f.load()
+	if len(f.data) != 0 {
+		t.Fatalf("Expected a missing file to be an empty store, got %v", f.data)
+	}
+	f.data["queen"] = 3
+	if err := f.save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be renamed, got %v", err)
+	}
+
+	reloaded := newTestJSONFile(path)
+	reloaded.load()
+	if reloaded.data["queen"] != 3 {
+		t.Errorf("Expected the saved value to be read back, got %v", reloaded.data)
+	}
+}
+
+func TestJSONFileResetsNull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	if err := os.WriteFile(path, []byte("null"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f := newTestJSONFile(path)
+	f.load()
+	if f.data == nil {
+		t.Fatalf("Expected reset to replace a null map")
+	}
+	f.data["genesis"] = 1
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// LocationSummary counts the concerts and artists of a location
+type LocationSummary struct {
+	Slug     string
+	Name     string
+	Concerts int
+	Artists  int
+}
+
+// CountrySummary rolls up the locations of a country
+type CountrySummary struct {
+	Slug      string
+	Name      string
+	Concerts  int
+	Artists   int
+	Locations []LocationSummary
+}
+
+type LocationsPageData struct {
+	Countries []CountrySummary
+	Locations int
+}
+
+// LocationPageData is a location or, when IsCountry is set, a whole country
+type LocationPageData struct {
+	Slug        string
+	Name        string
+	IsCountry   bool
+	CountrySlug string
+	CountryName string
+	Cities      []LocationSummary
+	Concerts    int
+	Sort        string
+	Results     []ArtistConcerts
+}
+
+// locationSorts are the accepted values of the sort parameter of location pages
+var locationSorts = map[string]bool{"date": true, "-date": true, "name": true}
+
+// formatCountry turns an API country into a display name, e.g. "USA" or "New Zealand"
+func formatCountry(country string) string {
+	if len(country) <= 3 {
+		return strings.ToUpper(country)
+	}
+	return titleCase(country)
+}
+
+// countrySlug turns a country as split from a location back into its API form
+func countrySlug(country string) string {
+	return strings.ReplaceAll(country, " ", "_")
+}
+
+// atLocation reports whether a concert took place at the slug: a location key such
+// as "osaka-japan", or a country such as "japan" for country rollups
+func atLocation(c Concert, slug string) bool {
+	if strings.Contains(slug, "-") {
+		return c.Location == slug
+	}
+	return countrySlug(c.Country) == slug
+}
+
+// locationArtists maps every location to the IDs of the artists listed there by
+// the locations dataset or the relations dataset
+func locationArtists(locations []api.Location, concerts []Concert) map[string]map[int]bool {
+	byLocation := map[string]map[int]bool{}
+	add := func(location string, id int) {
+		if byLocation[location] == nil {
+			byLocation[location] = map[int]bool{}
+		}
+		byLocation[location][id] = true
+	}
+	for _, l := range locations {
+		for _, location := range l.Locations {
+			add(strings.ToLower(location), l.ID)
+		}
+	}
+	for _, c := range concerts {
+		add(c.Location, c.ArtistID)
+	}
+	return byLocation
+}
+
+// buildLocationIndex groups every location by country, both sorted by name
+func buildLocationIndex(locations []api.Location, relations []api.Relation) []CountrySummary {
+	concerts := buildConcerts(relations)
+	concertCounts := map[string]int{}
+	for _, c := range concerts {
+		concertCounts[c.Location]++
+	}
+
+	byCountry := map[string]*CountrySummary{}
+	countryArtists := map[string]map[int]bool{}
+	for location, artists := range locationArtists(locations, concerts) {
+		_, country := splitLocation(location)
+		slug := countrySlug(country)
+		summary, ok := byCountry[slug]
+		if !ok {
+			summary = &CountrySummary{Slug: slug, Name: formatCountry(country)}
+			byCountry[slug] = summary
+			countryArtists[slug] = map[int]bool{}
+		}
+		summary.Locations = append(summary.Locations, LocationSummary{
+			Slug:     location,
+			Name:     formatLocation(location),
+			Concerts: concertCounts[location],
+			Artists:  len(artists),
+		})
+		summary.Concerts += concertCounts[location]
+		for id := range artists {
+			countryArtists[slug][id] = true
+		}
+	}
+
+	countries := make([]CountrySummary, 0, len(byCountry))
+	for slug, summary := range byCountry {
+		summary.Artists = len(countryArtists[slug])
+		sort.Slice(summary.Locations, func(i, j int) bool { return summary.Locations[i].Name < summary.Locations[j].Name })
+		countries = append(countries, *summary)
+	}
+	sort.Slice(countries, func(i, j int) bool { return countries[i].Name < countries[j].Name })
+	return countries
+}
+
+// buildLocationPage lists the artists who played at a location or in a country with
+// their dates. ok is false when nobody played there.
+func buildLocationPage(slug, sortBy string, artists []api.Artist, locations []api.Location, relations []api.Relation) (LocationPageData, bool) {
+	slug = strings.ToLower(slug)
+	concerts := buildConcerts(relations)
+	data := LocationPageData{Slug: slug, Sort: sortBy, IsCountry: !strings.Contains(slug, "-")}
+
+	ids := map[int]bool{}
+	for location, artistIDs := range locationArtists(locations, concerts) {
+		_, country := splitLocation(location)
+		if location == slug || (data.IsCountry && countrySlug(country) == slug) {
+			for id := range artistIDs {
+				ids[id] = true
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return data, false
+	}
+
+	byArtist := map[int][]Concert{}
+	for _, c := range concerts {
+		if atLocation(c, slug) {
+			byArtist[c.ArtistID] = append(byArtist[c.ArtistID], c)
+			data.Concerts++
+		}
+	}
+	for _, a := range artists {
+		if ids[a.ID] {
+			data.Results = append(data.Results, ArtistConcerts{Artist: a, Concerts: byArtist[a.ID]})
+		}
+	}
+	sortLocationResults(data.Results, sortBy)
+
+	if data.IsCountry {
+		data.Name = formatCountry(strings.ReplaceAll(slug, "_", " "))
+		for _, country := range buildLocationIndex(locations, relations) {
+			if country.Slug == slug {
+				data.Cities = country.Locations
+			}
+		}
+	} else {
+		_, country := splitLocation(slug)
+		data.Name = formatLocation(slug)
+		data.CountrySlug = countrySlug(country)
+		data.CountryName = formatCountry(country)
+	}
+	return data, true
+}
+
+// sortLocationResults orders artists by their first concert ("date"), their latest
+// concert ("-date", newest first) or by name. Artists without dates come last.
+func sortLocationResults(results []ArtistConcerts, sortBy string) {
+	if sortBy == "-date" {
+		for _, r := range results {
+			for i, j := 0, len(r.Concerts)-1; i < j; i, j = i+1, j-1 {
+				r.Concerts[i], r.Concerts[j] = r.Concerts[j], r.Concerts[i]
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if sortBy != "name" && (len(a.Concerts) == 0) != (len(b.Concerts) == 0) {
+			return len(b.Concerts) == 0
+		}
+		if sortBy == "name" || len(a.Concerts) == 0 {
+			return a.Artist.Name < b.Artist.Name
+		}
+		if sortBy == "-date" {
+			return a.Concerts[0].Time.After(b.Concerts[0].Time)
+		}
+		return a.Concerts[0].Time.Before(b.Concerts[0].Time)
+	})
+}
+
+// ServeLocations handles the /locations index
+func ServeLocations(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	_, locations, _, relations := getCachedData()
+	data := LocationsPageData{Countries: buildLocationIndex(locations, relations)}
+	for _, country := range data.Countries {
+		data.Locations += len(country.Locations)
+	}
+	renderTemplate(w, http.StatusOK, "templates/locations.html", data)
+}
+
+// ServeLocation handles the /location/{slug} pages of locations and countries
+func ServeLocation(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/location/"), "/")
+	if slug == "" {
+		http.Redirect(w, r, "/locations", http.StatusFound)
+		return
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = "date"
+	}
+	if !locationSorts[sortBy] {
+		ErrorHandler(w, "Invalid sort order", http.StatusBadRequest, true, true)
+		return
+	}
+
+	initCache()
+	artists, locations, _, relations := getCachedData()
+	data, ok := buildLocationPage(slug, sortBy, artists, locations, relations)
+	if !ok {
+		ErrorHandler(w, "No concerts were found at this location", http.StatusNotFound, true, true)
+		return
+	}
+	renderTemplate(w, http.StatusOK, "templates/location.html", data)
+}
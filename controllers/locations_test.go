@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBuildLocationIndex(t *testing.T) {
+	countries := buildLocationIndex(fixtureLocations, fixtureRelations)
+
+	var names []string
+	for _, c := range countries {
+		names = append(names, c.Name)
+	}
+	if names[0] != "France" || names[len(names)-1] != "USA" {
+		t.Errorf("Expected countries sorted by name, got %v", names)
+	}
+
+	for _, c := range countries {
+		switch c.Slug {
+		case "usa":
+			if c.Concerts != 4 || c.Artists != 2 || len(c.Locations) != 3 {
+				t.Errorf("Expected 4 concerts by 2 artists in 3 US locations, got %+v", c)
+			}
+			if c.Locations[0].Name != "Georgia, USA" {
+				t.Errorf("Expected locations sorted by name, got %+v", c.Locations)
+			}
+		case "new_zealand":
+			if c.Name != "New Zealand" {
+				t.Errorf("Expected New Zealand, got %q", c.Name)
+			}
+		}
+	}
+}
+
+func TestBuildLocationPage(t *testing.T) {
+	data, ok := buildLocationPage("berlin-germany", "date", fixtureArtists, fixtureLocations, fixtureRelations)
+	if !ok || data.Name != "Berlin, Germany" || data.Concerts != 3 || data.CountrySlug != "germany" {
+		t.Fatalf("Unexpected page %+v", data)
+	}
+	if len(data.Results) != 3 || data.Results[0].Artist.Name != "Pink Floyd" {
+		t.Errorf("Expected Pink Floyd to have played Berlin first, got %+v", data.Results)
+	}
+
+	data, _ = buildLocationPage("berlin-germany", "-date", fixtureArtists, fixtureLocations, fixtureRelations)
+	if data.Results[2].Artist.Name != "Pink Floyd" {
+		t.Errorf("Expected Pink Floyd last when sorting by latest date, got %+v", data.Results)
+	}
+
+	data, _ = buildLocationPage("london-uk", "-date", fixtureArtists, fixtureLocations, fixtureRelations)
+	for _, r := range data.Results {
+		if r.Artist.Name == "Pink Floyd" && r.Concerts[0].Date != "2019-04-15" {
+			t.Errorf("Expected dates newest first, got %+v", r.Concerts)
+		}
+	}
+
+	if _, ok := buildLocationPage("atlantis-ocean", "date", fixtureArtists, fixtureLocations, fixtureRelations); ok {
+		t.Errorf("Expected no page for an unknown location")
+	}
+}
+
+func TestBuildCountryPage(t *testing.T) {
+	data, ok := buildLocationPage("usa", "name", fixtureArtists, fixtureLocations, fixtureRelations)
+	if !ok || !data.IsCountry || data.Name != "USA" || len(data.Cities) != 3 {
+		t.Fatalf("Unexpected country page %+v", data)
+	}
+	if len(data.Results) != 2 || data.Results[0].Artist.Name != "Genesis" || len(data.Results[1].Concerts) != 3 {
+		t.Errorf("Expected Genesis and Queen's 3 US concerts, got %+v", data.Results)
+	}
+}
+
+func TestServeLocation(t *testing.T) {
+	rr := serveAPI(ServeLocation, "GET", "/location/nairobi-kenya")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{"Nairobi, Kenya", "SOJA", "Phil Collins", "2020-03-05", `href="/location/kenya"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the page to contain %q", expected)
+		}
+	}
+
+	if rr := serveAPI(ServeLocation, "GET", "/location/nairobi-kenya?sort=random"); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid sort order") {
+		t.Errorf("Expected an invalid sort order error, got %v", rr.Code)
+	}
+	if rr := serveAPI(ServeLocation, "GET", "/location/atlantis"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "No concerts were found") {
+		t.Errorf("Expected a not found error, got %v", rr.Code)
+	}
+}
+
+func TestServeLocations(t *testing.T) {
+	rr := serveAPI(ServeLocations, "GET", "/locations")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `<a href="/location/osaka-japan">Osaka, Japan</a>`) {
+		t.Errorf("Expected the index to link to Osaka")
+	}
+}
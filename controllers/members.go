@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// MemberBand is a band a member plays in
+type MemberBand struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// Member is a person listed in the members of one or more artists
+type Member struct {
+	Slug  string       `json:"slug"`
+	Name  string       `json:"name"`
+	Bands []MemberBand `json:"bands"`
+}
+
+// Bandmate is someone who played in one of the bands of a member
+type Bandmate struct {
+	Slug string
+	Name string
+	Band string
+}
+
+type MemberPageData struct {
+	Member    Member
+	Bandmates []Bandmate
+}
+
+type MembersPageData struct {
+	Shared  []Member
+	Members int
+}
+
+// memberSlug turns a member name into a stable URL slug: lower case letters and
+// digits separated by dashes, "Roger Meddows-Taylor" giving "roger-meddows-taylor"
+func memberSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// buildMembers lists every member by name with the bands they play in. Names with
+// the same slug are the same person, so a solo artist listed as their own member
+// and as a band member is one member with two bands.
+func buildMembers(artists []api.Artist) []Member {
+	bySlug := map[string]*Member{}
+	var slugs []string
+	for _, a := range artists {
+		for _, name := range a.Members {
+			slug := memberSlug(name)
+			if slug == "" {
+				continue
+			}
+			m, ok := bySlug[slug]
+			if !ok {
+				m = &Member{Slug: slug, Name: strings.TrimSpace(name)}
+				bySlug[slug] = m
+				slugs = append(slugs, slug)
+			}
+			if len(m.Bands) == 0 || m.Bands[len(m.Bands)-1].ID != a.ID {
+				m.Bands = append(m.Bands, MemberBand{ID: a.ID, Name: a.Name, Image: a.Image})
+			}
+		}
+	}
+
+	members := make([]Member, 0, len(slugs))
+	for _, slug := range slugs {
+		m := *bySlug[slug]
+		sort.Slice(m.Bands, func(i, j int) bool { return m.Bands[i].Name < m.Bands[j].Name })
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Slug < members[j].Slug })
+	return members
+}
+
+// sharedMembers returns the members who play in more than one band
+func sharedMembers(members []Member) []Member {
+	shared := []Member{}
+	for _, m := range members {
+		if len(m.Bands) > 1 {
+			shared = append(shared, m)
+		}
+	}
+	return shared
+}
+
+// findMember looks a member up by slug in members sorted by slug
+func findMember(members []Member, slug string) (Member, bool) {
+	i := sort.Search(len(members), func(i int) bool { return members[i].Slug >= slug })
+	if i < len(members) && members[i].Slug == slug {
+		return members[i], true
+	}
+	return Member{}, false
+}
+
+// artistSharedMembers returns the members of an artist who also play in other bands
+func artistSharedMembers(members []Member, a api.Artist) []Member {
+	shared := []Member{}
+	for _, name := range a.Members {
+		if m, ok := findMember(members, memberSlug(name)); ok && len(m.Bands) > 1 {
+			shared = append(shared, m)
+		}
+	}
+	return shared
+}
+
+// bandmates lists the other members of the bands of a member
+func bandmates(artists []api.Artist, m Member) []Bandmate {
+	var mates []Bandmate
+	for _, band := range m.Bands {
+		a, ok := findArtist(artists, band.ID)
+		if !ok {
+			continue
+		}
+		for _, name := range a.Members {
+			if slug := memberSlug(name); slug != m.Slug {
+				mates = append(mates, Bandmate{Slug: slug, Name: name, Band: a.Name})
+			}
+		}
+	}
+	return mates
+}
+
+// searchMembers returns the artists with a member whose name contains the query,
+// for the member: search qualifier
+func searchMembers(artists []api.Artist, query string) []ArtistResult {
+	var results []ArtistResult
+	for _, a := range artists {
+		for _, name := range a.Members {
+			if match := matchField("member", name, query); match != nil {
+				results = append(results, ArtistResult{Artist: a, Match: match})
+				break
+			}
+		}
+	}
+	return results
+}
+
+// getMembers returns the members found on the last refresh
+func getMembers() []Member {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return memberCache
+}
+
+// GetMembersHandler handles the /api/v1/members route. shared=true limits the list
+// to the members who play in more than one band.
+func GetMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	members := getMembers()
+	if r.URL.Query().Get("shared") == "true" {
+		members = sharedMembers(members)
+	}
+	if members == nil {
+		members = []Member{}
+	}
+	writeJSON(w, http.StatusOK, members, nil)
+}
+
+// GetMemberHandler handles the /api/v1/members/{slug} route
+func GetMemberHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	slug := strings.TrimPrefix(r.URL.Path, apiPrefix+"/members/")
+	m, ok := findMember(getMembers(), slug)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "member not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, m, nil)
+}
+
+// ServeMember handles the /member/{slug} pages. An empty slug redirects to the
+// list of members.
+func ServeMember(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/member/"), "/")
+	if slug == "" {
+		http.Redirect(w, r, "/members", http.StatusFound)
+		return
+	}
+
+	initCache()
+	m, ok := findMember(getMembers(), slug)
+	if !ok {
+		ErrorHandler(w, "Member not found", http.StatusNotFound, true, true)
+		return
+	}
+	artists, _, _, _ := getCachedData()
+	renderTemplate(w, http.StatusOK, "templates/member.html", MemberPageData{Member: m, Bandmates: bandmates(artists, m)})
+}
+
+// ServeMembers handles the /members page, listing the members shared across bands
+func ServeMembers(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	members := getMembers()
+	renderTemplate(w, http.StatusOK, "templates/members.html", MembersPageData{Shared: sharedMembers(members), Members: len(members)})
+}
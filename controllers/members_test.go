@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMemberSlug(t *testing.T) {
+	tests := map[string]string{
+		"Phil Collins":         "phil-collins",
+		"Roger Meddows-Taylor": "roger-meddows-taylor",
+		"  Mr. X  ":            "mr-x",
+		"Björk":                "björk",
+		"***":                  "",
+	}
+	for name, expected := range tests {
+		if slug := memberSlug(name); slug != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, slug)
+		}
+	}
+}
+
+func TestBuildMembers(t *testing.T) {
+	members := buildMembers(fixtureArtists)
+	expected := 0
+	for _, a := range fixtureArtists {
+		expected += len(a.Members)
+	}
+	// Phil Collins is a member of Genesis and of his solo act
+	if len(members) != expected-1 {
+		t.Errorf("Expected %d members, got %d", expected-1, len(members))
+	}
+	for i := 1; i < len(members); i++ {
+		if members[i].Slug <= members[i-1].Slug {
+			t.Fatalf("Expected members sorted by slug, got %s after %s", members[i].Slug, members[i-1].Slug)
+		}
+	}
+
+	shared := sharedMembers(members)
+	if len(shared) != 1 || shared[0].Name != "Phil Collins" || len(shared[0].Bands) != 2 ||
+		shared[0].Bands[0].Name != "Genesis" || shared[0].Bands[1].Name != "Phil Collins" {
+		t.Errorf("Expected Phil Collins in Genesis and Phil Collins, got %+v", shared)
+	}
+
+	if m, ok := findMember(members, "brian-may"); !ok || m.Bands[0].Name != "Queen" {
+		t.Errorf("Expected Brian May in Queen, got %+v (%v)", m, ok)
+	}
+	if _, ok := findMember(members, "nobody"); ok {
+		t.Errorf("Expected an unknown slug not to be found")
+	}
+
+	if shared := artistSharedMembers(members, fixtureArtists[3]); len(shared) != 1 || shared[0].Slug != "phil-collins" {
+		t.Errorf("Expected Phil Collins as the shared member of Genesis, got %+v", shared)
+	}
+	if mates := bandmates(fixtureArtists, shared[0]); len(mates) != 3 || mates[0].Band != "Genesis" {
+		t.Errorf("Expected the 3 other members of Genesis as bandmates, got %+v", mates)
+	}
+}
+
+func TestSearchMemberQualifier(t *testing.T) {
+	results := searchArtists(fixtureArtists, fixtureRelations, "member:collins")
+	if len(results) != 2 || results[0].Name != "Genesis" || results[1].Name != "Phil Collins" {
+		t.Fatalf("Expected Genesis and Phil Collins, got %+v", results)
+	}
+	if m := results[0].Match; m.Field != "member" || m.Text() != "Collins" {
+		t.Errorf("Expected the member name to be highlighted, got %+v", m)
+	}
+
+	// Unlike a plain search, member: does not match artist names
+	if results := searchArtists(fixtureArtists, fixtureRelations, "member:queen"); len(results) != 0 {
+		t.Errorf("Expected no member named queen, got %+v", results)
+	}
+}
+
+func TestMemberHandlers(t *testing.T) {
+	rr := serveAPI(GetMembersHandler, "GET", "/api/v1/members?shared=true")
+	var members []Member
+	decodeAPIResponse(t, rr, &members)
+	if len(members) != 1 || members[0].Slug != "phil-collins" {
+		t.Errorf("Expected only Phil Collins to be shared, got %+v", members)
+	}
+
+	rr = serveAPI(GetMemberHandler, "GET", "/api/v1/members/nick-mason")
+	var member Member
+	decodeAPIResponse(t, rr, &member)
+	if member.Name != "Nick Mason" || len(member.Bands) != 1 || member.Bands[0].ID != 3 {
+		t.Errorf("Expected Nick Mason of Pink Floyd, got %+v", member)
+	}
+	rr = serveAPI(GetMemberHandler, "GET", "/api/v1/members/nobody")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+
+	rr = serveAPI(ServeMember, "GET", "/member/phil-collins")
+	body := rr.Body.String()
+	if !strings.Contains(body, `href="/artist/4">Genesis</a>`) || !strings.Contains(body, `<a href="/member/tony-banks">Tony Banks</a>`) {
+		t.Errorf("Expected the member page to list the bands and bandmates")
+	}
+	rr = serveAPI(ServeMember, "GET", "/member/")
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/members" {
+		t.Errorf("Expected a redirect to /members, got %v %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = serveAPI(ServeMember, "GET", "/member/nobody")
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "Member not found") {
+		t.Errorf("Expected a not found error for an unknown member, got %v", rr.Code)
+	}
+
+	rr = serveAPI(ServeMembers, "GET", "/members")
+	if body := rr.Body.String(); !strings.Contains(body, `<a href="/member/phil-collins">Phil Collins</a>`) {
+		t.Errorf("Expected the members page to list Phil Collins")
+	}
+
+	rr = negotiated(ServeArtistDetails, "/artist/4", "text/html")
+	if body := rr.Body.String(); !strings.Contains(body, `<a href="/member/peter-gabriel">Peter Gabriel</a>`) || !strings.Contains(body, "Also in other bands") {
+		t.Errorf("Expected the artist page to link its members")
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/geo"
+)
+
+const (
+	// nearDefaultKm is the search radius when none is given
+	nearDefaultKm = 100
+	// nearMaxKm is half the circumference of the Earth, which covers every place
+	nearMaxKm = 20038
+)
+
+// NearbyConcert is a concert found by a radius search, with its distance from the centre
+type NearbyConcert struct {
+	Concert
+	Artist     string  `json:"artist"`
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// concertsNear returns the concerts within km of the centre, closest first and then
+// by date. Concerts at locations that cannot be geocoded are left out.
+func concertsNear(artists []api.Artist, concerts []Concert, center geo.Coordinates, km float64) []NearbyConcert {
+	names := make(map[int]string, len(artists))
+	for _, a := range artists {
+		names[a.ID] = a.Name
+	}
+
+	nearby := []NearbyConcert{}
+	for _, c := range concerts {
+		coords, ok := geocoder.Lookup(c.Location)
+		if !ok {
+			continue
+		}
+		if d := geo.Distance(center, coords); d <= km {
+			nearby = append(nearby, NearbyConcert{Concert: c, Artist: names[c.ArtistID], DistanceKm: roundKm(d)})
+		}
+	}
+	// concerts are already chronological, so a stable sort keeps dates in order
+	sort.SliceStable(nearby, func(i, j int) bool { return nearby[i].DistanceKm < nearby[j].DistanceKm })
+	return nearby
+}
+
+// parseNearParams reads the centre of a radius search from lat and lon or from a
+// city name, and the radius from km
+func parseNearParams(values url.Values) (geo.Coordinates, float64, error) {
+	var center geo.Coordinates
+	km := float64(nearDefaultKm)
+	if s := strings.TrimSpace(values.Get("km")); s != "" {
+		parsed, err := parseFinite(s)
+		if err != nil || parsed <= 0 || parsed > nearMaxKm {
+			return center, 0, fmt.Errorf("km must be a distance between 0 and %d", nearMaxKm)
+		}
+		km = parsed
+	}
+
+	if city := strings.TrimSpace(values.Get("city")); city != "" {
+		_, coords, ok := geocoder.Find(city)
+		if !ok {
+			return center, 0, fmt.Errorf("unknown city %q", city)
+		}
+		return coords, km, nil
+	}
+
+	lat, errLat := parseFinite(values.Get("lat"))
+	lon, errLon := parseFinite(values.Get("lon"))
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return center, 0, fmt.Errorf("either a city or valid lat and lon coordinates are required")
+	}
+	return geo.Coordinates{Lat: lat, Lon: lon}, km, nil
+}
+
+// parseFinite parses a number, refusing NaN and infinities, which every range
+// check would let through or misplace
+func parseFinite(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = fmt.Errorf("%q is not a finite number", s)
+	}
+	return f, err
+}
+
+// searchNear answers the near: search qualifier with the artists who played within
+// nearDefaultKm of the place, ordered by their closest concert
+func searchNear(artists []api.Artist, relations []api.Relation, place string) []ArtistResult {
+	_, center, ok := geocoder.Find(place)
+	if !ok {
+		return nil
+	}
+
+	byID := make(map[int]api.Artist, len(artists))
+	for _, a := range artists {
+		byID[a.ID] = a
+	}
+	var results []ArtistResult
+	seen := map[int]bool{}
+	for _, c := range concertsNear(artists, buildConcerts(relations), center, nearDefaultKm) {
+		a, ok := byID[c.ArtistID]
+		if !ok || seen[a.ID] {
+			continue
+		}
+		seen[a.ID] = true
+		name := formatLocation(c.Location)
+		value := fmt.Sprintf("%s, %s (%.0f km)", name, c.Date, c.DistanceKm)
+		results = append(results, ArtistResult{Artist: a, Match: &SearchMatch{Field: "near", Value: value, Start: 0, End: len(name)}})
+	}
+	return results
+}
+
+// NearConcertsHandler handles the /api/v1/concerts/near route
+func NearConcertsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	center, km, err := parseNearParams(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	writeJSON(w, http.StatusOK, concertsNear(artists, buildConcerts(relations), center, km), nil)
+}
+
+// roundKm rounds a distance to 0.1 km
+func roundKm(km float64) float64 {
+	return math.Round(km*10) / 10
+}
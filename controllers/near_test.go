@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/geo"
+)
+
+func TestConcertsNear(t *testing.T) {
+	_, paris, _ := geocoder.Find("paris")
+	nearby := concertsNear(fixtureArtists, buildConcerts(fixtureRelations), paris, 500)
+
+	// Paris itself, then the four London concerts 343 km away in date order
+	if len(nearby) != 5 {
+		t.Fatalf("Expected 5 concerts within 500 km of Paris, got %+v", nearby)
+	}
+	if nearby[0].Location != "paris-france" || nearby[0].DistanceKm != 0 {
+		t.Errorf("Expected Paris first, got %+v", nearby[0])
+	}
+	for i := 2; i < len(nearby); i++ {
+		if nearby[i].Date < nearby[i-1].Date {
+			t.Errorf("Expected concerts at the same distance in date order, got %s before %s", nearby[i-1].Date, nearby[i].Date)
+		}
+	}
+	if nearby[1].Artist != "Pink Floyd" || nearby[1].DistanceKm < 340 || nearby[1].DistanceKm > 345 {
+		t.Errorf("Expected Pink Floyd in London next, got %+v", nearby[1])
+	}
+}
+
+func TestParseNearParams(t *testing.T) {
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"lat=-1.29&lon=36.82&km=50", true},
+		{"city=nairobi", true},
+		{"city=atlantis", false},
+		{"lat=100&lon=0", false},
+		{"lat=1", false},
+		{"city=nairobi&km=-5", false},
+		{"city=nairobi&km=far", false},
+		{"city=nairobi&km=NaN", false},
+		{"city=nairobi&km=Inf", false},
+		{"lat=NaN&lon=36.82", false},
+		{"lat=-1.29&lon=nan", false},
+		{"lat=+Inf&lon=36.82", false},
+		{"lat=-1.29&lon=-Infinity", false},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		_, _, err := parseNearParams(values)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got error %v", tt.query, tt.valid, err)
+		}
+	}
+
+	values, _ := url.ParseQuery("city=nairobi")
+	center, km, _ := parseNearParams(values)
+	if km != nearDefaultKm || geo.Distance(center, geo.Coordinates{Lat: -1.29, Lon: 36.82}) > 1 {
+		t.Errorf("Expected Nairobi with the default radius, got %+v within %v km", center, km)
+	}
+}
+
+func TestNearConcertsHandler(t *testing.T) {
+	rr := serveAPI(NearConcertsHandler, "GET", "/api/v1/concerts/near?lat=-1.3&lon=36.8&km=10")
+	var nearby []NearbyConcert
+	decodeAPIResponse(t, rr, &nearby)
+	if len(nearby) != 2 || nearby[0].Artist != "SOJA" || nearby[1].Artist != "Phil Collins" {
+		t.Errorf("Expected the two Nairobi concerts in date order, got %+v", nearby)
+	}
+
+	rr = serveAPI(NearConcertsHandler, "GET", "/api/v1/concerts/near?km=10")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+}
+
+func TestSearchNearQualifier(t *testing.T) {
+	results := searchArtists(fixtureArtists, fixtureRelations, "near:Berlin")
+	if len(results) != 3 || results[0].Name != "Pink Floyd" {
+		t.Fatalf("Expected the 3 artists who played Berlin, earliest first, got %+v", results)
+	}
+	if results[0].Match.Field != "near" || results[0].Match.Text() != "Berlin, Germany" {
+		t.Errorf("Expected the match to name the place, got %+v", results[0].Match)
+	}
+
+	if results := searchArtists(fixtureArtists, fixtureRelations, "near:atlantis"); len(results) != 0 {
+		t.Errorf("Expected no results near an unknown place, got %+v", results)
+	}
+	if results := searchArtists(fixtureArtists, fixtureRelations, "queen"); len(results) != 1 {
+		t.Errorf("Expected unqualified queries to search as before, got %+v", results)
+	}
+
+	rr := serveAPI(ServeArtists, "GET", "/?query=near%3Anairobi")
+	if body := rr.Body.String(); !strings.Contains(body, "SOJA") || strings.Contains(body, "Pink Floyd") {
+		t.Errorf("Expected the search bar to answer near: queries")
+	}
+}
+
+func TestParseQualifier(t *testing.T) {
+	if name, value, ok := parseQualifier(" Near: nairobi "); !ok || name != "near" || value != "nairobi" {
+		t.Errorf("Unexpected qualifier %q %q %v", name, value, ok)
+	}
+	for _, query := range []string{"queen", "near:", "who played: here"} {
+		if _, _, ok := parseQualifier(query); ok {
+			t.Errorf("Expected %q not to be a qualified query", query)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// Response formats the HTML pages can also be served in
+const (
+	formatHTML = "html"
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+var (
+	artistCSVHeader  = []string{"id", "name", "members", "creation_date", "first_album", "locations"}
+	concertCSVHeader = []string{"date", "artist_id", "artist", "location", "city", "country"}
+)
+
+// formatForMediaType maps a media type of an Accept header to a response format.
+// Wildcards select HTML, so browsers and plain curl keep getting the page.
+func formatForMediaType(mediaType string) string {
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "text/*", "*/*":
+		return formatHTML
+	case "application/json":
+		return formatJSON
+	case "text/csv":
+		return formatCSV
+	}
+	return ""
+}
+
+// negotiateFormat picks the response format of a page. A "format" query parameter
+// overrides the Accept header, which is read by quality value. ok is false when the
+// client accepts none of the formats.
+func negotiateFormat(r *http.Request) (string, bool) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		switch format {
+		case formatHTML, formatJSON, formatCSV:
+			return format, true
+		}
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatHTML, true
+	}
+
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		format := formatForMediaType(strings.ToLower(strings.TrimSpace(mediaType)))
+		if format != "" && quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+	return best, best != ""
+}
+
+// negotiate sets the Vary header and picks the response format, answering
+// 406 Not Acceptable when there is none
+func negotiate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	format, ok := negotiateFormat(r)
+	if !ok {
+		http.Error(w, "Supported formats are text/html, application/json and text/csv", http.StatusNotAcceptable)
+	}
+	return format, ok
+}
+
+// negotiatedError reports an error in the requested format
+func negotiatedError(w http.ResponseWriter, format, message string, statusCode int) {
+	switch format {
+	case formatJSON:
+		writeJSONError(w, statusCode, message)
+	case formatCSV:
+		http.Error(w, message, statusCode)
+	default:
+		ErrorHandler(w, message, statusCode, true, true)
+	}
+}
+
+// writeCSV streams a header and rows as a CSV response. A non-empty filename
+// makes browsers download the file.
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	if filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		if err := cw.Write(csvSafeRow(row)); err != nil {
+			break
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Error writing CSV: %v", err)
+	}
+}
+
+// csvSafeRow prefixes the cells a spreadsheet would read as a formula, those starting
+// with =, +, -, @, a tab or a carriage return, with a quote so they stay text
+func csvSafeRow(row []string) []string {
+	safe := make([]string, len(row))
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+		safe[i] = cell
+	}
+	return safe
+}
+
+// artistCSVRows flattens artists into rows of artistCSVHeader. Members and
+// locations are separated by semicolons.
+func artistCSVRows(artists []api.Artist) [][]string {
+	rows := make([][]string, 0, len(artists))
+	for _, a := range artists {
+		rows = append(rows, []string{
+			strconv.Itoa(a.ID),
+			a.Name,
+			strings.Join(a.Members, "; "),
+			strconv.Itoa(a.CreationDate),
+			a.FirstAlbum,
+			strings.ReplaceAll(a.Locations, ", ", "; "),
+		})
+	}
+	return rows
+}
+
+// concertCSVRows flattens concerts into rows of concertCSVHeader
+func concertCSVRows(artists []api.Artist, concerts []Concert) [][]string {
+	names := make(map[int]string, len(artists))
+	for _, a := range artists {
+		names[a.ID] = a.Name
+	}
+	rows := make([][]string, 0, len(concerts))
+	for _, c := range concerts {
+		rows = append(rows, []string{c.Date, strconv.Itoa(c.ArtistID), names[c.ArtistID], c.Location, c.City, c.Country})
+	}
+	return rows
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		target   string
+		accept   string
+		expected string
+		ok       bool
+	}{
+		{"/", "", formatHTML, true},
+		{"/", "text/html,application/xhtml+xml,*/*;q=0.8", formatHTML, true},
+		{"/", "*/*", formatHTML, true},
+		{"/", "application/json", formatJSON, true},
+		{"/", "text/html;q=0.5, text/csv", formatCSV, true},
+		{"/", "application/xml", "", false},
+		{"/?format=csv", "application/json", formatCSV, true},
+		{"/?format=JSON", "", formatJSON, true},
+		{"/?format=xml", "", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		format, ok := negotiateFormat(req)
+		if format != tt.expected || ok != tt.ok {
+			t.Errorf("%s with Accept %q: expected %q (%v), got %q (%v)", tt.target, tt.accept, tt.expected, tt.ok, format, ok)
+		}
+	}
+}
+
+func negotiated(handler http.HandlerFunc, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept", accept)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestServeArtistsAsJSON(t *testing.T) {
+	rr := negotiated(ServeArtists, "/?query=collins", "application/json")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if rr.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected Vary: Accept, got %q", rr.Header().Get("Vary"))
+	}
+
+	var results []ArtistResult
+	decodeAPIResponse(t, rr, &results)
+	if len(results) != 2 || results[0].Name != "Genesis" || results[1].Name != "Phil Collins" {
+		t.Errorf("Expected the same results as the page, got %+v", results)
+	}
+}
+
+func TestServeArtistsAsCSV(t *testing.T) {
+	rr := negotiated(ServeArtists, "/?query=queen&format=csv", "text/html")
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Expected a CSV response, got %q", ct)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(artistCSVHeader, ",") {
+		t.Fatalf("Expected a header and one row, got %v", records)
+	}
+	if records[1][1] != "Queen" || !strings.Contains(records[1][2], "Freddie Mercury; ") || !strings.Contains(records[1][5], "osaka-japan") {
+		t.Errorf("Unexpected row %v", records[1])
+	}
+}
+
+func TestServeArtistsPlayedInStaysJSON(t *testing.T) {
+	rr := negotiated(ServeArtists, "/?query=played+in+japan", "application/json")
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected JSON requests not to be redirected to the concerts page; got %v", rr.Code)
+	}
+}
+
+func TestServeArtistDetailsNegotiation(t *testing.T) {
+	rr := negotiated(ServeArtistDetails, "/artist/4", "application/json")
+	var artist ArtistDetail
+	decodeAPIResponse(t, rr, &artist)
+	if artist.Name != "Genesis" || len(artist.Concerts) != 3 {
+		t.Errorf("Expected Genesis with 3 concerts, got %+v", artist)
+	}
+
+	rr = negotiated(ServeArtistDetails, "/artist/4?format=csv", "")
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 4 || records[1][0] != "2019-08-20" || records[1][2] != "Genesis" || records[1][3] != "los_angeles-usa" {
+		t.Errorf("Expected the concerts of Genesis in order, got %v", records)
+	}
+
+	rr = negotiated(ServeArtistDetails, "/artist/999", "application/json")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+	decodeAPIError(t, rr)
+
+	rr = negotiated(ServeArtistDetails, "/artist/1", "image/png")
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status Not Acceptable; got %v", rr.Code)
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeCSV(rr, "", []string{"name"}, [][]string{
+		{"=HYPERLINK(\"http://evil.example\")"}, {"+1+1"}, {"-2+3"}, {"@SUM(A1)"}, {"\t=1"}, {"Queen"}, {"2019-08-20"},
+	})
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	expected := []string{"name", "'=HYPERLINK(\"http://evil.example\")", "'+1+1", "'-2+3", "'@SUM(A1)", "'\t=1", "Queen", "2019-08-20"}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %v", len(expected), records)
+	}
+	for i, record := range records {
+		if record[0] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], record[0])
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+const (
+	// notificationQueueSize is the number of refreshes waiting to be notified
+	// before further ones are dropped
+	notificationQueueSize = 16
+	// webhookWorkers is the number of webhooks posted at the same time
+	webhookWorkers = 4
+	// webhookAttempts is the number of times a webhook is posted before its
+	// notification is given up
+	webhookAttempts = 3
+)
+
+// webhookRetryDelay is the wait before the second attempt at a webhook, doubled for
+// each further one. Tests shorten it.
+var webhookRetryDelay = 5 * time.Second
+
+// notificationBatch holds the changes of one refresh, with the data they are
+// matched against
+type notificationBatch struct {
+	changes   []Change
+	locations []api.Location
+	relations []api.Relation
+}
+
+// webhookDelivery is a notification waiting to be posted to the webhook of a search
+type webhookDelivery struct {
+	search  SavedSearch
+	changes []Change
+}
+
+// notifier sends the notifications of the refreshes from background goroutines, so
+// a refresh never waits for the inbox file or for a webhook
+type notifier struct {
+	start    sync.Once
+	batches  chan notificationBatch
+	webhooks chan webhookDelivery
+	pending  sync.WaitGroup
+}
+
+var notifications = &notifier{
+	batches:  make(chan notificationBatch, notificationQueueSize),
+	webhooks: make(chan webhookDelivery, notificationQueueSize*maxSavedSearches),
+}
+
+// Queue adds the changes of a refresh to the notifications to send. It never
+// blocks: when the queue is full the changes are dropped.
+func (n *notifier) Queue(changes []Change, locations []api.Location, relations []api.Relation) {
+	if len(changes) == 0 {
+		return
+	}
+	n.start.Do(n.run)
+	n.pending.Add(1)
+	select {
+	case n.batches <- notificationBatch{changes, locations, relations}:
+	default:
+		n.pending.Done()
+		log.Printf("Notification queue full, dropping %d changes", len(changes))
+	}
+}
+
+// Wait blocks until the queued notifications are sent or given up
+func (n *notifier) Wait() {
+	n.pending.Wait()
+}
+
+// run starts the goroutine matching the refreshes against the saved searches, one
+// at a time so inboxes stay in order, and the goroutines posting the webhooks
+func (n *notifier) run() {
+	go func() {
+		for batch := range n.batches {
+			for _, d := range notifySavedSearches(batch.changes, batch.locations, batch.relations) {
+				n.pending.Add(1)
+				select {
+				case n.webhooks <- d:
+				default:
+					n.pending.Done()
+					log.Printf("Webhook queue full, dropping the notification of search %s", d.search.ID)
+				}
+			}
+			n.pending.Done()
+		}
+	}()
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for d := range n.webhooks {
+				deliverWebhook(d)
+				n.pending.Done()
+			}
+		}()
+	}
+}
+
+// deliverWebhook posts a notification to its webhook, retrying with a growing wait
+// while the webhook cannot be reached or answers with a server error
+func deliverWebhook(d webhookDelivery) {
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := postWebhook(d.search, d.changes)
+		if err == nil {
+			return
+		}
+		if !retry {
+			log.Printf("Webhook of search %s refused the notification: %v", d.search.ID, err)
+			return
+		}
+		if attempt == webhookAttempts {
+			log.Printf("Missed the webhook of search %s after %d attempts: %v", d.search.ID, attempt, err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postWebhook posts the changes matching a saved search to its webhook. It reports
+// whether a failure is worth retrying: client errors such as 404 mean the webhook
+// will not take the notification, however often it is sent.
+func postWebhook(search SavedSearch, changes []Change) (retry bool, err error) {
+	body, err := json.Marshal(WebhookPayload{Search: search, Changes: changes})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, search.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "groupie-tracker")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("answered %s", resp.Status)
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withLocalWebhooks lets webhooks reach the test servers and retry without waiting
+func withLocalWebhooks(t *testing.T) {
+	t.Helper()
+	previousDelay := webhookRetryDelay
+	allowWebhookIP = func(netip.Addr) bool { return true }
+	webhookRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		allowWebhookIP = publicIP
+		webhookRetryDelay = previousDelay
+	})
+}
+
+func TestRefreshDoesNotWaitForWebhooks(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	withTempSavedSearches(t)
+	withLocalWebhooks(t)
+	defer func() {
+		seedTestCache()
+		cacheMutex.Lock()
+		changeLog = nil
+		cacheMutex.Unlock()
+	}()
+
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hook.Close()
+
+	alice := loggedIn(t, "alice")
+	postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {"location:japan"}, "webhook": {hook.URL}})
+
+	refreshed := make(chan struct{})
+	go func() {
+		artists, relations := withNewData()
+		storeCache(artists, fixtureLocations, fixtureDates, relations)
+		close(refreshed)
+	}()
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the refresh to return while the webhook is pending")
+	}
+
+	close(release)
+	notifications.Wait()
+	if inbox, _ := savedSearches.Inbox("alice"); len(inbox) != 1 {
+		t.Errorf("Expected the notification in the inbox, got %+v", inbox)
+	}
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	withLocalWebhooks(t)
+
+	tests := []struct {
+		status   int
+		attempts int32
+	}{
+		{http.StatusOK, 1},
+		{http.StatusNoContent, 1},
+		// Client errors are not retried: the webhook will not take the notification
+		{http.StatusNotFound, 1},
+		{http.StatusGone, 1},
+		{http.StatusBadRequest, 1},
+		{http.StatusTooManyRequests, webhookAttempts},
+		{http.StatusInternalServerError, webhookAttempts},
+		{http.StatusServiceUnavailable, webhookAttempts},
+	}
+	for _, tt := range tests {
+		var hits atomic.Int32
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(tt.status)
+		}))
+		deliverWebhook(webhookDelivery{search: SavedSearch{ID: "test", Query: "queen", Webhook: hook.URL}})
+		hook.Close()
+		if got := hits.Load(); got != tt.attempts {
+			t.Errorf("Status %d: expected %d attempts, got %d", tt.status, tt.attempts, got)
+		}
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	withLocalWebhooks(t)
+
+	var hits atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer hook.Close()
+
+	retry, err := postWebhook(SavedSearch{ID: "test", Webhook: hook.URL}, nil)
+	if err == nil || !retry {
+		t.Errorf("Expected a server error to be retried, got %v %v", retry, err)
+	}
+	if retry, err := postWebhook(SavedSearch{ID: "test", Webhook: hook.URL}, nil); err != nil || retry {
+		t.Errorf("Expected the second post to be delivered, got %v %v", retry, err)
+	}
+}
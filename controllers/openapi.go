@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// Schema is the subset of the OpenAPI 3 schema object used to describe the API
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// APIParam documents a query or path parameter of an API route
+type APIParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+}
+
+// APIRoute is a JSON API route: how it is registered, and how it is documented.
+// Response is a value of the type found in the "data" field of the envelope, or
+// of the whole body of Bare routes. Routes with a RequestBody also accept it as a
+// POST.
+type APIRoute struct {
+	Pattern     string
+	Path        string
+	Handler     http.HandlerFunc
+	Summary     string
+	Params      []APIParam
+	Response    interface{}
+	Paginated   bool
+	Bare        bool
+	MediaType   string
+	RequestBody interface{}
+	Admin       bool
+}
+
+var (
+	pageParams = []APIParam{
+		{"page", "query", "integer", "Page number, starting at 1"},
+		{"per_page", "query", "integer", "Items per page, at most 100"},
+	}
+	artistFilterParams = []APIParam{
+		{"q", "query", "string", "Search text matched against names, members, dates and locations, or a qualified query such as near:nairobi or member:collins"},
+		{"location", "query", "string", "City or country the artist played in"},
+		{"members_min", "query", "integer", "Minimum number of members"},
+		{"members_max", "query", "integer", "Maximum number of members"},
+		{"created_from", "query", "integer", "Earliest creation year"},
+		{"created_to", "query", "integer", "Latest creation year"},
+		{"first_album_from", "query", "integer", "Earliest first album year"},
+		{"first_album_to", "query", "integer", "Latest first album year"},
+	}
+	concertFilterParams = []APIParam{
+		{"location", "query", "string", "City or country of the concert"},
+		{"from", "query", "string", "Start of the date range: YYYY, YYYY-MM or YYYY-MM-DD"},
+		{"to", "query", "string", "End of the date range: YYYY, YYYY-MM or YYYY-MM-DD"},
+		{"q", "query", "string", "A question such as \"played in Germany in 2019\""},
+	}
+	idParam     = APIParam{"id", "path", "integer", "Artist ID"}
+	formatParam = APIParam{"format", "query", "string", "json to get JSON without an Accept: application/json header"}
+)
+
+// apiRoutes lists every route of the JSON API. RegisterRoutes registers them and
+// the OpenAPI document describes them, so the two cannot drift apart.
+var apiRoutes = []APIRoute{
+	{
+		Pattern:   apiPrefix + "/artists",
+		Path:      apiPrefix + "/artists",
+		Handler:   GetArtistsHandler,
+		Summary:   "List artists",
+		Params:    append(append([]APIParam{}, artistFilterParams...), pageParams...),
+		Response:  []ArtistResult{},
+		Paginated: true,
+	},
+	{
+		Pattern:  apiPrefix + "/artists/",
+		Path:     apiPrefix + "/artists/{id}",
+		Handler:  GetArtistByIDHandler,
+		Summary:  "Get an artist with its concerts",
+		Params:   []APIParam{idParam},
+		Response: ArtistDetail{},
+	},
+	{
+		Pattern:  apiPrefix + "/artists/{id}/similar",
+		Path:     apiPrefix + "/artists/{id}/similar",
+		Handler:  GetSimilarArtistsHandler,
+		Summary:  "Artists similar to an artist, most similar first",
+		Params:   []APIParam{idParam},
+		Response: []SimilarArtist{},
+	},
+	{
+		Pattern:  apiPrefix + "/favorites",
+		Path:     apiPrefix + "/favorites",
+		Handler:  GetFavoritesHandler,
+		Summary:  "List the favorite artists of the session cookie, most recently added first",
+		Response: []api.Artist{},
+	},
+	{
+		Pattern:  apiPrefix + "/members",
+		Path:     apiPrefix + "/members",
+		Handler:  GetMembersHandler,
+		Summary:  "List the members of every artist with their bands",
+		Params:   []APIParam{{"shared", "query", "boolean", "Only return members who play in more than one band"}},
+		Response: []Member{},
+	},
+	{
+		Pattern:  apiPrefix + "/members/",
+		Path:     apiPrefix + "/members/{slug}",
+		Handler:  GetMemberHandler,
+		Summary:  "Get a member with their bands",
+		Params:   []APIParam{{"slug", "path", "string", "Member slug, such as phil-collins"}},
+		Response: Member{},
+	},
+	{
+		Pattern:  apiPrefix + "/locations",
+		Path:     apiPrefix + "/locations",
+		Handler:  GetLocationsHandler,
+		Summary:  "List the concert locations of every artist",
+		Response: []api.Location{},
+	},
+	{
+		Pattern:  apiPrefix + "/dates",
+		Path:     apiPrefix + "/dates",
+		Handler:  GetDatesHandler,
+		Summary:  "List the concert dates of every artist",
+		Response: []api.Date{},
+	},
+	{
+		Pattern:  apiPrefix + "/relations",
+		Path:     apiPrefix + "/relations",
+		Handler:  GetRelationsHandler,
+		Summary:  "List the concert dates of every artist by location",
+		Response: []api.Relation{},
+	},
+	{
+		Pattern:  apiPrefix + "/concerts",
+		Path:     apiPrefix + "/concerts",
+		Handler:  SearchConcertsHandler,
+		Summary:  "Search concerts by location and date range",
+		Params:   concertFilterParams,
+		Response: []ArtistConcerts{},
+	},
+	{
+		Pattern: apiPrefix + "/concerts/near",
+		Path:    apiPrefix + "/concerts/near",
+		Handler: NearConcertsHandler,
+		Summary: "Find concerts within a radius, closest first",
+		Params: []APIParam{
+			{"lat", "query", "number", "Latitude of the centre"},
+			{"lon", "query", "number", "Longitude of the centre"},
+			{"city", "query", "string", "City to use as the centre instead of lat and lon"},
+			{"km", "query", "number", "Radius in kilometres, 100 by default"},
+		},
+		Response: []NearbyConcert{},
+	},
+	{
+		Pattern:  apiPrefix + "/cobills",
+		Path:     apiPrefix + "/cobills",
+		Handler:  GetCoBillsHandler,
+		Summary:  "Locations and days shared by several artists",
+		Params:   []APIParam{{"artist", "query", "integer", "Only return the bills of this artist"}},
+		Response: []CoBill{},
+	},
+	{
+		Pattern:  apiPrefix + "/timeline",
+		Path:     apiPrefix + "/timeline",
+		Handler:  GetTimelineHandler,
+		Summary:  "Concerts grouped by year and month",
+		Params:   []APIParam{{"year", "query", "integer", "Only return this year"}},
+		Response: []TimelineYear{},
+	},
+	{
+		Pattern:  apiPrefix + "/compare",
+		Path:     apiPrefix + "/compare",
+		Handler:  GetComparisonHandler,
+		Summary:  "Compare artists side by side",
+		Params:   []APIParam{{"ids", "query", "string", "Comma separated IDs of 2 to 6 artists (required)"}},
+		Response: Comparison{},
+	},
+	{
+		Pattern:  apiPrefix + "/stats",
+		Path:     apiPrefix + "/stats",
+		Handler:  GetStatsHandler,
+		Summary:  "Catalog statistics computed on the last cache refresh",
+		Response: CatalogStats{},
+	},
+	{
+		Pattern:   apiPrefix + "/search",
+		Path:      apiPrefix + "/search",
+		Handler:   SearchHandler,
+		Summary:   "Search artists, explaining each match",
+		Params:    append([]APIParam{{"q", "query", "string", "Search text (required)"}}, pageParams...),
+		Response:  []ArtistResult{},
+		Paginated: true,
+	},
+	{
+		Pattern:  apiPrefix + "/suggestions",
+		Path:     apiPrefix + "/suggestions",
+		Handler:  SuggestionsHandler,
+		Summary:  "Search bar suggestions",
+		Params:   []APIParam{{"q", "query", "string", "Text typed so far"}},
+		Response: []Suggestion{},
+	},
+}
+
+// pageJSONRoutes lists the JSON routes outside /api/v1: pages that also answer in
+// JSON, the GraphQL endpoint and the admin reports. RegisterRoutes registers them
+// with the pages they share a path with, and the OpenAPI document describes them
+// along with the API.
+var pageJSONRoutes = []APIRoute{
+	{
+		Path:     "/",
+		Handler:  ServeArtists,
+		Summary:  "Search artists, as JSON when asked for by the Accept header or the format parameter",
+		Params:   []APIParam{{"query", "query", "string", "Search text"}, formatParam},
+		Response: []ArtistResult{},
+	},
+	{
+		Path:     "/artists",
+		Handler:  ServeArtists,
+		Summary:  "Search artists, as JSON when asked for by the Accept header or the format parameter",
+		Params:   []APIParam{{"query", "query", "string", "Search text"}, formatParam},
+		Response: []ArtistResult{},
+	},
+	{
+		Path:     "/artist/{id}",
+		Handler:  ServeArtistDetails,
+		Summary:  "Get an artist with its concerts, as JSON when asked for by the Accept header or the format parameter",
+		Params:   []APIParam{idParam, formatParam},
+		Response: ArtistDetail{},
+	},
+	{
+		Path:      "/artist/{id}/concerts.geojson",
+		Handler:   ArtistGeoJSONHandler,
+		Summary:   "The concerts of an artist as GeoJSON points, with a line for the tour path",
+		Params:    []APIParam{idParam},
+		Response:  GeoJSONFeatureCollection{},
+		Bare:      true,
+		MediaType: "application/geo+json",
+	},
+	{
+		Path:     "/search-suggestions",
+		Handler:  GetSearchSuggestionsHandler,
+		Summary:  "Search bar suggestions of the pages, as \"value - category\" strings",
+		Params:   []APIParam{{"q", "query", "string", "Text typed so far"}},
+		Response: []string{},
+		Bare:     true,
+	},
+	{
+		Path:    "/graphql",
+		Handler: GraphQLHandler,
+		Summary: "Run a GraphQL query, from the query parameters of a GET or the JSON body of a POST",
+		Params: []APIParam{
+			{"query", "query", "string", "GraphQL query"},
+			{"operationName", "query", "string", "Operation to run when the query holds several"},
+			{"variables", "query", "string", "Variables as a JSON object"},
+		},
+		Response:    GraphQLResponse{},
+		Bare:        true,
+		RequestBody: GraphQLRequest{},
+	},
+	{
+		Path:     "/admin/integrity",
+		Handler:  IntegrityHandler,
+		Summary:  "Data integrity report of the last cache refresh",
+		Response: IntegrityReport{},
+		Bare:     true,
+		Admin:    true,
+	},
+	{
+		Path:     "/admin/geocoding",
+		Handler:  GeocodingHandler,
+		Summary:  "Geocoding report of the last cache refresh",
+		Response: GeocodingReport{},
+		Bare:     true,
+		Admin:    true,
+	},
+}
+
+// documentedRoutes returns every route described by the OpenAPI document
+func documentedRoutes() []APIRoute {
+	return append(append([]APIRoute{}, apiRoutes...), pageJSONRoutes...)
+}
+
+// schemaFor describes a Go type the way encoding/json marshals it. Named structs
+// are added to components and referenced.
+func schemaFor(t reflect.Type, components map[string]*Schema) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), components)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), components)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), components)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, components)
+		}
+		if _, ok := components[t.Name()]; !ok {
+			components[t.Name()] = &Schema{} // placeholder for recursive types
+			components[t.Name()] = structSchema(t, components)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// interface{} and anything else may hold any value
+	return &Schema{}
+}
+
+// structSchema describes the JSON object of a struct, flattening embedded structs
+func structSchema(t reflect.Type, components map[string]*Schema) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := structSchema(f.Type, components)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schemaFor(f.Type, components)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// openAPIDocument builds the OpenAPI 3 description of the JSON API
+func openAPIDocument() map[string]interface{} {
+	components := map[string]*Schema{}
+	errorRef := schemaFor(reflect.TypeOf(APIError{}), components)
+	metaRef := schemaFor(reflect.TypeOf(APIMeta{}), components)
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorRef}},
+		}
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range documentedRoutes() {
+		body := schemaFor(reflect.TypeOf(route.Response), components)
+		if !route.Bare {
+			envelope := &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"data": body},
+				Required:   []string{"data"},
+			}
+			if route.Paginated {
+				envelope.Properties["meta"] = metaRef
+				envelope.Required = append(envelope.Required, "meta")
+			}
+			body = envelope
+		}
+		mediaType := route.MediaType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+
+		params := []map[string]interface{}{}
+		for _, p := range route.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      &Schema{Type: p.Type},
+			})
+		}
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Success",
+				"content":     map[string]interface{}{mediaType: map[string]interface{}{"schema": body}},
+			},
+			"400":     errorResponse("Invalid parameters"),
+			"default": errorResponse("Error"),
+		}
+		if route.Admin {
+			responses["401"] = errorResponse("The admin token is missing")
+			responses["403"] = errorResponse("The admin token is wrong, or the request does not come from localhost")
+		}
+
+		get := map[string]interface{}{
+			"summary":    route.Summary,
+			"parameters": params,
+			"responses":  responses,
+		}
+		if route.Admin {
+			get["security"] = []map[string][]string{{"adminToken": {}}}
+		}
+		operations := map[string]interface{}{"get": get}
+		if route.RequestBody != nil {
+			operations["post"] = map[string]interface{}{
+				"summary": route.Summary,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(route.RequestBody), components)},
+					},
+				},
+				"responses": responses,
+			}
+		}
+		paths[route.Path] = operations
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Groupie Tracker API",
+			"version":     "1.0.0",
+			"description": "Artists, concerts and locations served from the Groupie Tracker cache.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": components,
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "The GROUPIE_ADMIN_TOKEN of the server. Without it, the admin routes are only served to localhost.",
+				},
+			},
+		},
+	}
+}
+
+// OpenAPIHandler handles the /api/openapi.json route
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(openAPIDocument()); err != nil {
+		log.Printf("Error encoding OpenAPI document: %v", err)
+	}
+}
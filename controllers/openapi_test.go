@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// validate checks a decoded JSON value against a schema, resolving references in components
+func validate(value interface{}, schema *Schema, components map[string]*Schema, path string) error {
+	if schema.Ref != "" {
+		ref, ok := components[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unresolved reference %s", path, schema.Ref)
+		}
+		return validate(value, ref, components, path)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				prop = schema.AdditionalProperties
+			}
+			if prop == nil {
+				return fmt.Errorf("%s: undocumented property %q", path, name)
+			}
+			if err := validate(v, prop, components, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		for i, v := range arr {
+			if err := validate(v, schema.Items, components, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	default:
+		return fmt.Errorf("%s: unknown schema type %q", path, schema.Type)
+	}
+	return nil
+}
+
+// documentSchemas decodes the served OpenAPI document back into schemas
+func documentSchemas(t *testing.T) (map[string]json.RawMessage, map[string]*Schema) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	OpenAPIHandler(rr, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Could not decode OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("Expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	return doc.Paths, doc.Components.Schemas
+}
+
+// responseSchema extracts the schema of a status code from a documented path,
+// whatever its media type
+func responseSchema(t *testing.T, raw json.RawMessage, status string) *Schema {
+	t.Helper()
+	var op struct {
+		Get struct {
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema *Schema `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"get"`
+	}
+	if err := json.Unmarshal(raw, &op); err != nil {
+		t.Fatalf("Could not decode path: %v", err)
+	}
+	resp, ok := op.Get.Responses[status]
+	if !ok || len(resp.Content) != 1 {
+		t.Fatalf("No %s response documented", status)
+	}
+	for _, content := range resp.Content {
+		return content.Schema
+	}
+	return nil
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	paths, _ := documentSchemas(t)
+	var documented []string
+	for p := range paths {
+		documented = append(documented, p)
+	}
+	sort.Strings(documented)
+
+	routes := documentedRoutes()
+	if len(paths) != len(routes) {
+		t.Errorf("Expected %d documented paths, got %v", len(routes), documented)
+	}
+	for _, route := range routes {
+		if _, ok := paths[route.Path]; !ok {
+			t.Errorf("Route %s is not documented", route.Path)
+		}
+	}
+}
+
+func TestAPIResponsesConformToOpenAPI(t *testing.T) {
+	paths, components := documentSchemas(t)
+
+	// Requests exercising each route; path parameters are filled in with fixture values
+	queries := map[string]string{
+		apiPrefix + "/artists":       "?q=queen",
+		apiPrefix + "/concerts":      "?location=germany",
+		apiPrefix + "/search":        "?q=phil",
+		apiPrefix + "/timeline":      "?year=2019",
+		apiPrefix + "/concerts/near": "?city=paris&km=500",
+		apiPrefix + "/compare":       "?ids=3,4,5",
+		"/":                          "?query=queen",
+		"/search-suggestions":        "?q=ph",
+		"/graphql":                   "?query=" + url.QueryEscape("{ artists { name members { name } } }"),
+	}
+	for _, route := range documentedRoutes() {
+		target := strings.NewReplacer("{id}", "1", "{slug}", "phil-collins").Replace(route.Path) + queries[route.Path]
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		route.Handler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status OK; got %v", target, rr.Code)
+			continue
+		}
+
+		var body interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: invalid JSON: %v", target, err)
+			continue
+		}
+		if err := validate(body, responseSchema(t, paths[route.Path], "200"), components, target); err != nil {
+			t.Errorf("Response does not conform to the schema: %v", err)
+		}
+	}
+}
+
+// routeExamples gives the paths to request for the prefix patterns of routes.go,
+// which dispatch several routes each
+var routeExamples = map[string][]string{
+	"/static/":   {"/static/styles.css"},
+	"/artist/":   {"/artist/1", "/artist/1/concerts.ics", "/artist/1/concerts.geojson"},
+	"/location/": {"/location/london-uk", "/location/london-uk/concerts.ics"},
+	"/member/":   {"/member/phil-collins"},
+	"/api/":      {"/api/v1/unknown"},
+}
+
+// registeredPatterns reads the literal patterns registered in routes.go
+func registeredPatterns(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "controllers/routes.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Could not parse routes.go: %v", err)
+	}
+	var patterns []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			pattern, _ := strconv.Unquote(lit.Value)
+			patterns = append(patterns, pattern)
+		}
+		return true
+	})
+	if len(patterns) == 0 {
+		t.Fatalf("Expected routes.go to register routes")
+	}
+	return patterns
+}
+
+// documentedPath finds the documented path template matching a request path
+func documentedPath(paths map[string]json.RawMessage, requestPath string) (string, bool) {
+	segments := strings.Split(requestPath, "/")
+	for template := range paths {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			if part != segments[i] && !(strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return template, true
+		}
+	}
+	return "", false
+}
+
+func TestEveryJSONRouteIsDocumented(t *testing.T) {
+	t.Setenv(adminTokenEnv, "")
+	paths, _ := documentSchemas(t)
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	for _, pattern := range registeredPatterns(t) {
+		targets := []string{pattern}
+		if pattern != "/" && strings.HasSuffix(pattern, "/") {
+			targets = routeExamples[pattern]
+			if len(targets) == 0 {
+				t.Errorf("Add example paths for the %s routes to routeExamples", pattern)
+			}
+		}
+		for _, target := range targets {
+			req := httptest.NewRequest("GET", target, nil)
+			req.Header.Set("Accept", "application/json")
+			req.RemoteAddr = "127.0.0.1:40000"
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			// Errors are JSON for every API route, so only successful responses count
+			if rr.Code != http.StatusOK || target == "/api/openapi.json" {
+				continue
+			}
+			isJSON := strings.Contains(rr.Header().Get("Content-Type"), "json") || json.Valid(rr.Body.Bytes())
+			if _, ok := documentedPath(paths, target); isJSON && !ok {
+				t.Errorf("%s answers in JSON but is not in the OpenAPI document", target)
+			}
+		}
+	}
+}
+
+func TestAPIErrorsConformToOpenAPI(t *testing.T) {
+	paths, components := documentSchemas(t)
+	path := apiPrefix + "/artists/{id}"
+
+	rr := httptest.NewRecorder()
+	GetArtistByIDHandler(rr, httptest.NewRequest("GET", apiPrefix+"/artists/abc", nil))
+	var body interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if err := validate(body, responseSchema(t, paths[path], "400"), components, "error"); err != nil {
+		t.Errorf("Error does not conform to the schema: %v", err)
+	}
+}
+
+func TestSchemaForFlattensEmbeddedStructs(t *testing.T) {
+	components := map[string]*Schema{}
+	ref := schemaFor(reflect.TypeOf(ArtistResult{}), components)
+	if ref.Ref != "#/components/schemas/ArtistResult" {
+		t.Fatalf("Expected a reference to ArtistResult, got %+v", ref)
+	}
+	s := components["ArtistResult"]
+	if _, ok := s.Properties["name"]; !ok {
+		t.Errorf("Expected the embedded artist's fields to be flattened, got %v", s.Properties)
+	}
+	for _, name := range s.Required {
+		if name == "match" {
+			t.Errorf("Expected omitempty fields not to be required")
+		}
+	}
+	if _, ok := components["SearchMatch"]; !ok {
+		t.Errorf("Expected nested structs to be added to the components")
+	}
+}
@@ -2,12 +2,94 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 )
 
+// RegisterRoutes registers the HTML pages and the JSON API on the default mux
 func RegisterRoutes() {
-	http.HandleFunc("/artists", ServeArtists)
-	http.HandleFunc("/artists/", GetArtistByIDHandler)
-	http.HandleFunc("/locations", GetLocationsHandler)
-	http.HandleFunc("/dates", GetDatesHandler)
-	http.HandleFunc("/relations", GetRelationsHandler)
+	registerRoutes(http.DefaultServeMux)
+}
+
+// registerRoutes registers every route of the application on mux
+func registerRoutes(mux *http.ServeMux) {
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
+
+	// HTML pages
+	mux.HandleFunc("/artists", ServeArtists)
+	mux.HandleFunc("/artist/", artistHandler)
+	mux.HandleFunc("/timeline", ServeTimeline)
+	mux.HandleFunc("/locations", ServeLocations)
+	mux.HandleFunc("/location/", locationHandler)
+	mux.HandleFunc("/stats", ServeStats)
+	mux.HandleFunc("/compare", ServeCompare)
+	mux.HandleFunc("/members", ServeMembers)
+	mux.HandleFunc("/member/", ServeMember)
+	mux.HandleFunc("/favorites", FavoritesHandler)
+	mux.HandleFunc("/register", RegisterHandler)
+	mux.HandleFunc("/login", LoginHandler)
+	mux.HandleFunc("/logout", LogoutHandler)
+	mux.HandleFunc("/searches", SavedSearchesHandler)
+	mux.HandleFunc("/inbox", InboxHandler)
+	mux.HandleFunc("/about", AboutHandler)
+	mux.HandleFunc("/concerts", ServeConcerts)
+	mux.HandleFunc("/search-suggestions", GetSearchSuggestionsHandler)
+	mux.HandleFunc("/admin/integrity", requireAdmin(IntegrityHandler))
+	mux.HandleFunc("/admin/geocoding", requireAdmin(GeocodingHandler))
+
+	// Feeds and exports
+	mux.HandleFunc("/feeds/changes.atom", ChangesFeedHandler)
+	mux.HandleFunc("/export/artists.csv", ExportArtistsHandler)
+	mux.HandleFunc("/export/concerts.csv", ExportConcertsHandler)
+	mux.HandleFunc("/export/cobills.dot", ExportCoBillsDOTHandler)
+	mux.HandleFunc("/export/cobills.graphml", ExportCoBillsGraphMLHandler)
+
+	// JSON API
+	mux.HandleFunc("/api/", APINotFoundHandler)
+	mux.HandleFunc("/api/openapi.json", OpenAPIHandler)
+	for _, route := range apiRoutes {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+	mux.HandleFunc("/graphql", GraphQLHandler)
+
+	// Catch-all for undefined routes
+	mux.HandleFunc("/", rootHandler)
+}
+
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if the request is for the root path or favicon.ico
+	if r.URL.Path == "/" || r.URL.Path == "/favicon.ico" {
+		ServeArtists(w, r)
+		return
+	}
+
+	// Check if the request is for an artist details page
+	if strings.HasPrefix(r.URL.Path, "/artist/") {
+		artistHandler(w, r)
+		return
+	}
+
+	// If it's not a known route, use ErrorHandler for 404
+	ErrorHandler(w, "Page Not Found", http.StatusNotFound, true, true)
+}
+
+// artistHandler dispatches the /artist/{id} pages, their calendar feeds and maps
+func artistHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/concerts.ics"):
+		ArtistCalendarHandler(w, r)
+		return
+	case strings.HasSuffix(r.URL.Path, "/concerts.geojson"):
+		ArtistGeoJSONHandler(w, r)
+		return
+	}
+	ServeArtistDetails(w, r)
+}
+
+// locationHandler dispatches the /location/{slug} routes
+func locationHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/concerts.ics") {
+		LocationCalendarHandler(w, r)
+		return
+	}
+	ServeLocation(w, r)
 }
@@ -0,0 +1,490 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// savedSearchesFile is where the saved searches and inboxes of the accounts are kept
+const savedSearchesFile = "data/searches.json"
+
+const (
+	// maxSavedSearches is the number of searches an account can save
+	maxSavedSearches = 20
+	// maxInboxEntries is the number of notifications kept per account, newest first
+	maxInboxEntries = 100
+)
+
+var (
+	errEmptySearch     = errors.New("Enter a search to save")
+	errTooManySearches = fmt.Errorf("You can save up to %d searches", maxSavedSearches)
+	errInvalidWebhook  = errors.New("Webhooks must be http or https URLs of public hosts")
+)
+
+// webhookClient posts the notifications of saved searches to their webhooks. It
+// checks the address of every connection once the host name is resolved, so a
+// webhook cannot reach the server itself or its private network, and it does not
+// follow redirects.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkWebhookDial,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// allowWebhookIP reports whether webhooks may connect to an address. Tests replace
+// it to reach their local servers.
+var allowWebhookIP = publicIP
+
+var errWebhookAddress = errors.New("webhooks may not connect to local or private addresses")
+
+// nonPublicPrefixes are the special-purpose ranges of the IANA registries webhooks
+// may not reach: local, private, shared, reserved and documentation networks, and
+// the IPv6 ranges that embed IPv4 addresses, which could lead back to them
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/32"),       // Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("fec0::/10"),       // site-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// publicIP reports whether an address is outside nonPublicPrefixes. IPv4-mapped
+// IPv6 addresses are checked as IPv4, and zones are dropped since prefixes never
+// contain zoned addresses.
+func publicIP(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookDial refuses connections to addresses allowWebhookIP rejects
+func checkWebhookDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !allowWebhookIP(addrPort.Addr()) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// SavedSearch is a search query an account is notified about. Webhook is an
+// optional URL the new matches are posted to.
+type SavedSearch struct {
+	ID      string    `json:"id"`
+	Query   string    `json:"query"`
+	Webhook string    `json:"webhook,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// InboxEntry notifies an account of an artist or concert matching a saved search
+type InboxEntry struct {
+	SearchID   string    `json:"searchId"`
+	Query      string    `json:"query"`
+	Kind       string    `json:"kind"`
+	Title      string    `json:"title"`
+	ArtistID   int       `json:"artistId"`
+	DetectedAt time.Time `json:"detectedAt"`
+	Read       bool      `json:"read"`
+}
+
+// WebhookPayload is the JSON body posted to the webhook of a saved search
+type WebhookPayload struct {
+	Search  SavedSearch `json:"search"`
+	Changes []Change    `json:"changes"`
+}
+
+// AccountNav holds the account links of the top bar
+type AccountNav struct {
+	User   string
+	CSRF   string
+	Unread int
+}
+
+type SavedSearchesPageData struct {
+	AccountNav
+	Searches []SavedSearch
+	Query    string
+	Webhook  string
+	Error    string
+}
+
+type InboxPageData struct {
+	AccountNav
+	Entries []InboxEntry
+}
+
+// savedSearchData is the content of the saved searches file
+type savedSearchData struct {
+	Searches map[string][]SavedSearch `json:"searches"`
+	Inboxes  map[string][]InboxEntry  `json:"inboxes"`
+}
+
+// savedSearchStore keeps the saved searches and inboxes of the accounts in a JSON file
+type savedSearchStore struct {
+	mu sync.Mutex
+	jsonFile[savedSearchData]
+}
+
+var savedSearches = newSavedSearchStore(savedSearchesFile)
+
+func newSavedSearchStore(path string) *savedSearchStore {
+	return &savedSearchStore{jsonFile: newJSONFile(path, "saved searches", func(d *savedSearchData) {
+		if d.Searches == nil {
+			d.Searches = map[string][]SavedSearch{}
+		}
+		if d.Inboxes == nil {
+			d.Inboxes = map[string][]InboxEntry{}
+		}
+	})}
+}
+
+// validWebhook checks that a webhook is an absolute http or https URL that does not
+// name a local or private host
+func validWebhook(webhook string) bool {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	// Host names are checked when they are resolved, addresses can be refused now
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !allowWebhookIP(addr) {
+		return false
+	}
+	return u.Hostname() != "localhost"
+}
+
+// List returns the saved searches of an account in the order they were saved
+func (s *savedSearchStore) List(user string) []SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return append([]SavedSearch{}, s.data.Searches[user]...)
+}
+
+// Add saves a search for an account. Saving a query again updates its webhook.
+func (s *savedSearchStore) Add(user, query, webhook string) (SavedSearch, error) {
+	query, webhook = strings.TrimSpace(query), strings.TrimSpace(webhook)
+	if query == "" {
+		return SavedSearch{}, errEmptySearch
+	}
+	if webhook != "" && !validWebhook(webhook) {
+		return SavedSearch{}, errInvalidWebhook
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	searches := s.data.Searches[user]
+	for i, search := range searches {
+		if strings.EqualFold(search.Query, query) {
+			searches[i].Webhook = webhook
+			return searches[i], s.save()
+		}
+	}
+	if len(searches) >= maxSavedSearches {
+		return SavedSearch{}, errTooManySearches
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return SavedSearch{}, err
+	}
+	search := SavedSearch{ID: hex.EncodeToString(b), Query: query, Webhook: webhook, Created: time.Now().UTC()}
+	s.data.Searches[user] = append(searches, search)
+	return search, s.save()
+}
+
+// Delete removes a saved search of an account
+func (s *savedSearchStore) Delete(user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	searches := s.data.Searches[user]
+	for i, search := range searches {
+		if search.ID == id {
+			if len(searches) == 1 {
+				delete(s.data.Searches, user)
+			} else {
+				s.data.Searches[user] = append(searches[:i:i], searches[i+1:]...)
+			}
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// All returns a copy of the saved searches of every account
+func (s *savedSearchStore) All() map[string][]SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	all := make(map[string][]SavedSearch, len(s.data.Searches))
+	for user, searches := range s.data.Searches {
+		all[user] = append([]SavedSearch{}, searches...)
+	}
+	return all
+}
+
+// Inbox returns the notifications of an account, newest first, and the number of
+// unread ones
+func (s *savedSearchStore) Inbox(user string) ([]InboxEntry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	entries := append([]InboxEntry{}, s.data.Inboxes[user]...)
+	unread := 0
+	for _, e := range entries {
+		if !e.Read {
+			unread++
+		}
+	}
+	return entries, unread
+}
+
+// Deliver adds notifications to the front of the inboxes of the accounts
+func (s *savedSearchStore) Deliver(entries map[string][]InboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	for user, added := range entries {
+		inbox := append(append([]InboxEntry{}, added...), s.data.Inboxes[user]...)
+		if len(inbox) > maxInboxEntries {
+			inbox = inbox[:maxInboxEntries]
+		}
+		s.data.Inboxes[user] = inbox
+	}
+	return s.save()
+}
+
+// MarkRead marks every notification of an account as read
+func (s *savedSearchStore) MarkRead(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	changed := false
+	for i := range s.data.Inboxes[user] {
+		if !s.data.Inboxes[user][i].Read {
+			s.data.Inboxes[user][i].Read = true
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Clear empties the inbox of an account
+func (s *savedSearchStore) Clear(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	if _, ok := s.data.Inboxes[user]; !ok {
+		return nil
+	}
+	delete(s.data.Inboxes, user)
+	return s.save()
+}
+
+// changeMatches reports whether a change matches a search query. A new concert is
+// searched as if the artist had only played it, so a location query matches the
+// concert rather than the places the artist played before.
+func changeMatches(c Change, locations []api.Location, relations []api.Relation, query string) bool {
+	if c.Concert == nil {
+		artist := withLocationNames([]api.Artist{c.Artist}, locations)
+		return len(searchArtists(artist, relations, query)) > 0
+	}
+	artist := c.Artist
+	artist.Locations = c.Concert.Location
+	played := api.Relation{ID: artist.ID, DatesLocations: map[string][]string{
+		c.Concert.Location: {c.Concert.Time.Format("02-01-2006")},
+	}}
+	return len(searchArtists([]api.Artist{artist}, []api.Relation{played}, query)) > 0
+}
+
+// notifySavedSearches delivers the changes of a refresh to the inboxes of the
+// accounts whose saved searches they match, and returns the notifications to post
+// to the webhooks of those searches
+func notifySavedSearches(changes []Change, locations []api.Location, relations []api.Relation) []webhookDelivery {
+	entries := map[string][]InboxEntry{}
+	var deliveries []webhookDelivery
+	for user, searches := range savedSearches.All() {
+		for _, search := range searches {
+			var matched []Change
+			for _, c := range changes {
+				if changeMatches(c, locations, relations, search.Query) {
+					matched = append(matched, c)
+					entries[user] = append(entries[user], InboxEntry{
+						SearchID:   search.ID,
+						Query:      search.Query,
+						Kind:       c.Kind,
+						Title:      c.Title(),
+						ArtistID:   c.Artist.ID,
+						DetectedAt: c.DetectedAt,
+					})
+				}
+			}
+			if len(matched) > 0 && search.Webhook != "" {
+				deliveries = append(deliveries, webhookDelivery{search: search, changes: matched})
+			}
+		}
+	}
+	if err := savedSearches.Deliver(entries); err != nil {
+		log.Printf("Error saving notifications: %v", err)
+	}
+	return deliveries
+}
+
+// accountNav returns the account links of a page with forms, creating the session
+// its forms need
+func accountNav(w http.ResponseWriter, r *http.Request) AccountNav {
+	nav := AccountNav{User: currentUser(r)}
+	_, nav.CSRF = pageSession(w, r)
+	if nav.User != "" {
+		_, nav.Unread = savedSearches.Inbox(nav.User)
+	}
+	return nav
+}
+
+// requireLogin returns the account of the request, redirecting to the login form
+// when logged out
+func requireLogin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := currentUser(r)
+	if user == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return "", false
+	}
+	return user, true
+}
+
+// SavedSearchesHandler handles the /searches route: GET lists the saved searches and
+// POST saves or deletes one
+func SavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowForm(w, r) {
+		return
+	}
+	user, ok := requireLogin(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		renderSavedSearches(w, r, http.StatusOK, SavedSearchesPageData{Query: r.URL.Query().Get("query")})
+		return
+	}
+
+	session, _ := browserSession(w, r, false)
+	if !checkCSRF(r, session) {
+		ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+		return
+	}
+	var err error
+	switch r.PostFormValue("action") {
+	case "save":
+		data := SavedSearchesPageData{Query: r.PostFormValue("query"), Webhook: r.PostFormValue("webhook")}
+		_, err = savedSearches.Add(user, data.Query, data.Webhook)
+		if errors.Is(err, errEmptySearch) || errors.Is(err, errTooManySearches) || errors.Is(err, errInvalidWebhook) {
+			data.Error = err.Error()
+			renderSavedSearches(w, r, http.StatusBadRequest, data)
+			return
+		}
+	case "delete":
+		err = savedSearches.Delete(user, r.PostFormValue("id"))
+	default:
+		ErrorHandler(w, "Invalid saved search action", http.StatusBadRequest, true, true)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving the searches of %s: %v", user, err)
+		ErrorHandler(w, "Your searches could not be saved. Please try again later.", http.StatusInternalServerError, true, true)
+		return
+	}
+	http.Redirect(w, r, "/searches", http.StatusSeeOther)
+}
+
+// renderSavedSearches renders the saved searches of the account
+func renderSavedSearches(w http.ResponseWriter, r *http.Request, status int, data SavedSearchesPageData) {
+	data.AccountNav = accountNav(w, r)
+	data.Searches = savedSearches.List(data.User)
+	renderTemplate(w, status, "templates/searches.html", data)
+}
+
+// InboxHandler handles the /inbox route: GET shows the notifications of the account,
+// marking them as read, and POST clears them
+func InboxHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowForm(w, r) {
+		return
+	}
+	user, ok := requireLogin(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodPost {
+		session, _ := browserSession(w, r, false)
+		if !checkCSRF(r, session) {
+			ErrorHandler(w, expiredFormMessage, http.StatusForbidden, true, true)
+			return
+		}
+		if err := savedSearches.Clear(user); err != nil {
+			log.Printf("Error clearing the inbox of %s: %v", user, err)
+		}
+		http.Redirect(w, r, "/inbox", http.StatusSeeOther)
+		return
+	}
+
+	data := InboxPageData{AccountNav: accountNav(w, r)}
+	data.Entries, _ = savedSearches.Inbox(user)
+	if err := savedSearches.MarkRead(user); err != nil {
+		log.Printf("Error marking the inbox of %s as read: %v", user, err)
+	}
+	renderTemplate(w, http.StatusOK, "templates/inbox.html", data)
+}
@@ -0,0 +1,369 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTempSavedSearches points the saved search store at a temporary file for one test
+func withTempSavedSearches(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "searches.json")
+	previous := savedSearches
+	savedSearches = newSavedSearchStore(path)
+	t.Cleanup(func() {
+		notifications.Wait()
+		savedSearches = previous
+	})
+	return path
+}
+
+// loggedIn registers an account and returns the cookie of a session logged in to it
+func loggedIn(t *testing.T, username string) *http.Cookie {
+	t.Helper()
+	if _, err := accounts.Register(username, "s3cret-pass"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	session, _ := newSessionID()
+	if err := accounts.Login(session, username); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &http.Cookie{Name: sessionCookie, Value: session}
+}
+
+func TestSavedSearchStore(t *testing.T) {
+	path := withTempSavedSearches(t)
+	search, err := savedSearches.Add("alice", " location:kenya ", "")
+	if err != nil || search.Query != "location:kenya" || search.ID == "" {
+		t.Fatalf("Expected the search to be saved, got %+v (%v)", search, err)
+	}
+	again, _ := savedSearches.Add("alice", "LOCATION:Kenya", "https://example.com/hook")
+	if again.ID != search.ID || len(savedSearches.List("alice")) != 1 || savedSearches.List("alice")[0].Webhook != "https://example.com/hook" {
+		t.Errorf("Expected saving a query again to update its webhook, got %+v", savedSearches.List("alice"))
+	}
+
+	for query, webhook := range map[string]string{"": "", "queen": "ftp://example.com", "genesis": "/relative"} {
+		if _, err := savedSearches.Add("alice", query, webhook); err == nil {
+			t.Errorf("%q %q: expected an error", query, webhook)
+		}
+	}
+	for _, webhook := range []string{"http://127.0.0.1:8080/", "http://localhost/", "http://[::1]/", "http://10.0.0.5/", "http://169.254.169.254/latest/meta-data", "http://0.0.0.0/", "http://0.1.2.3/", "http://100.64.0.1/", "http://198.18.0.1/", "http://[64:ff9b::a9fe:a9fe]/", "http://[::ffff:127.0.0.1]/"} {
+		if _, err := savedSearches.Add("alice", "queen", webhook); err != errInvalidWebhook {
+			t.Errorf("%q: expected errInvalidWebhook, got %v", webhook, err)
+		}
+	}
+	for i := len(savedSearches.List("alice")); i < maxSavedSearches; i++ {
+		savedSearches.Add("alice", "query "+string(rune('a'+i)), "")
+	}
+	if _, err := savedSearches.Add("alice", "one too many", ""); err != errTooManySearches {
+		t.Errorf("Expected errTooManySearches, got %v", err)
+	}
+
+	savedSearches.Delete("alice", search.ID)
+	reloaded := newSavedSearchStore(path)
+	if searches := reloaded.List("alice"); len(searches) != maxSavedSearches-1 || searches[0].Query == "location:kenya" {
+		t.Errorf("Expected the deletion to be persisted, got %d searches", len(searches))
+	}
+}
+
+func TestInboxStore(t *testing.T) {
+	withTempSavedSearches(t)
+	var entries []InboxEntry
+	for i := 0; i < maxInboxEntries+5; i++ {
+		entries = append(entries, InboxEntry{ArtistID: i})
+	}
+	savedSearches.Deliver(map[string][]InboxEntry{"alice": entries[:5]})
+	savedSearches.Deliver(map[string][]InboxEntry{"alice": entries[5:]})
+
+	inbox, unread := savedSearches.Inbox("alice")
+	if len(inbox) != maxInboxEntries || unread != maxInboxEntries || inbox[0].ArtistID != 5 {
+		t.Errorf("Expected the %d newest entries, got %d (%d unread) starting with %d", maxInboxEntries, len(inbox), unread, inbox[0].ArtistID)
+	}
+	savedSearches.MarkRead("alice")
+	if _, unread := savedSearches.Inbox("alice"); unread != 0 {
+		t.Errorf("Expected every entry to be read, got %d unread", unread)
+	}
+	savedSearches.Clear("alice")
+	if inbox, _ := savedSearches.Inbox("alice"); len(inbox) != 0 {
+		t.Errorf("Expected an empty inbox, got %d entries", len(inbox))
+	}
+}
+
+func TestChangeMatches(t *testing.T) {
+	artists, relations := withNewData()
+	changes := detectChanges(fixtureArtists, fixtureRelations, artists, relations, time.Now())
+	gorillaz, tokyo := changes[0], changes[1]
+
+	tests := []struct {
+		query           string
+		gorillaz, tokyo bool
+	}{
+		{"location:japan", false, true},
+		{"location:uk", true, false},
+		// Queen played the USA before, but the new concert is in Japan
+		{"location:usa", false, false},
+		{"queen", false, true},
+		{"gorillaz", true, false},
+		{"member:albarn", true, false},
+	}
+	for _, tt := range tests {
+		if got := changeMatches(gorillaz, fixtureLocations, relations, tt.query); got != tt.gorillaz {
+			t.Errorf("%q: expected %v for Gorillaz, got %v", tt.query, tt.gorillaz, got)
+		}
+		if got := changeMatches(tokyo, fixtureLocations, relations, tt.query); got != tt.tokyo {
+			t.Errorf("%q: expected %v for the Tokyo concert, got %v", tt.query, tt.tokyo, got)
+		}
+	}
+}
+
+func TestSavedSearchNotifications(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	withTempSavedSearches(t)
+	defer func() {
+		seedTestCache()
+		cacheMutex.Lock()
+		changeLog = nil
+		cacheMutex.Unlock()
+	}()
+
+	// The webhook server listens on the loopback address
+	allowWebhookIP = func(netip.Addr) bool { return true }
+	defer func() { allowWebhookIP = publicIP }()
+
+	payloads := make(chan WebhookPayload, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(body, &payload) != nil {
+			t.Errorf("Expected a JSON POST, got %s %q: %s", r.Method, r.Header.Get("Content-Type"), body)
+		}
+		payloads <- payload
+	}))
+	defer hook.Close()
+
+	alice := loggedIn(t, "alice")
+	bob := loggedIn(t, "bob")
+	rr := postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {"location:japan"}, "webhook": {hook.URL}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/searches" {
+		t.Fatalf("Expected the search to be saved, got %v", rr.Code)
+	}
+	postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {"gorillaz"}})
+	postForm(SavedSearchesHandler, "/searches", bob, url.Values{"action": {"save"}, "query": {"location:kenya"}})
+
+	artists, relations := withNewData()
+	storeCache(artists, fixtureLocations, fixtureDates, relations)
+	notifications.Wait()
+
+	select {
+	case payload := <-payloads:
+		if payload.Search.Query != "location:japan" || len(payload.Changes) != 1 || payload.Changes[0].Concert.Location != "tokyo-japan" {
+			t.Errorf("Expected the Tokyo concert to be posted, got %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to be called")
+	}
+
+	inbox, unread := savedSearches.Inbox("alice")
+	if len(inbox) != 2 || unread != 2 {
+		t.Fatalf("Expected 2 notifications for alice, got %+v", inbox)
+	}
+	if inbox[0].Query != "location:japan" || inbox[0].Title != "Queen played Tokyo, Japan on 1 February 2020" || inbox[1].Title != "New artist: Gorillaz" {
+		t.Errorf("Unexpected notifications %+v", inbox)
+	}
+	if inbox, _ := savedSearches.Inbox("bob"); len(inbox) != 0 {
+		t.Errorf("Expected no notification for bob, got %+v", inbox)
+	}
+
+	rr = getWithCookie(ServeArtists, "/", alice)
+	if !strings.Contains(rr.Body.String(), `<span class="unread-count">2</span>`) {
+		t.Errorf("Expected the unread count in the top bar")
+	}
+	rr = getWithCookie(InboxHandler, "/inbox", alice)
+	if body := rr.Body.String(); !strings.Contains(body, "Queen played Tokyo, Japan") || !strings.Contains(body, `href="/artist/6"`) || !strings.Contains(body, "inbox-entry unread") {
+		t.Errorf("Expected the inbox page to list the unread notifications")
+	}
+	if _, unread := savedSearches.Inbox("alice"); unread != 0 {
+		t.Errorf("Expected viewing the inbox to mark it read, got %d unread", unread)
+	}
+
+	postForm(InboxHandler, "/inbox", alice, url.Values{})
+	if inbox, _ := savedSearches.Inbox("alice"); len(inbox) != 0 {
+		t.Errorf("Expected the inbox to be cleared, got %+v", inbox)
+	}
+}
+
+func TestSavedSearchesHandler(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	withTempSavedSearches(t)
+
+	// Logged out browsers are sent to the login form
+	for _, handler := range []http.HandlerFunc{SavedSearchesHandler, InboxHandler} {
+		rr := serveAPI(handler, "GET", "/searches")
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login" {
+			t.Errorf("Expected a redirect to the login form, got %v %q", rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	alice := loggedIn(t, "alice")
+	rr := getWithCookie(ServeArtists, "/?query=location:kenya", alice)
+	if !strings.Contains(rr.Body.String(), `class="save-search-form"`) || !strings.Contains(rr.Body.String(), `value="location:kenya"`) {
+		t.Errorf("Expected a form saving the search on the artists page")
+	}
+
+	rr = postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {"location:kenya"}, "webhook": {"file:///etc/passwd"}})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Webhooks must be http or https URLs") {
+		t.Errorf("Expected an invalid webhook to be refused, got %v", rr.Code)
+	}
+	postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {"location:kenya"}})
+	rr = getWithCookie(SavedSearchesHandler, "/searches", alice)
+	searches := savedSearches.List("alice")
+	if len(searches) != 1 || !strings.Contains(rr.Body.String(), `value="`+searches[0].ID+`"`) {
+		t.Fatalf("Expected the saved search to be listed, got %+v", searches)
+	}
+
+	postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"delete"}, "id": {searches[0].ID}})
+	if searches := savedSearches.List("alice"); len(searches) != 0 {
+		t.Errorf("Expected the search to be deleted, got %+v", searches)
+	}
+	if rr := postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"rename"}}); !strings.Contains(rr.Body.String(), "Invalid saved search action") {
+		t.Errorf("Expected an unknown action to be refused")
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700::1111", true},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.0.0.5", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"172.32.0.1", true},
+		{"192.0.0.170", false},
+		{"192.0.2.1", false},
+		{"192.88.99.1", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"198.20.0.1", true},
+		{"198.51.100.1", false},
+		{"203.0.113.1", false},
+		{"224.0.0.251", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::127.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.5", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b:1::1", false},
+		{"100::1", false},
+		{"2001::1", false},
+		{"2001:db8::1", false},
+		{"2002:7f00:1::1", false},
+		{"fc00::1", false},
+		{"fd12:3456::1", false},
+		{"fe80::1", false},
+		{"fe80::1%eth0", false},
+		{"fec0::1", false},
+		{"ff02::1", false},
+	}
+	for _, tt := range tests {
+		if got := publicIP(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("publicIP(%s): expected %v, got %v", tt.addr, tt.public, got)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer hook.Close()
+
+	for _, target := range []string{hook.URL, "http://169.254.169.254/latest/meta-data"} {
+		resp, err := webhookClient.Post(target, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, errWebhookAddress) {
+			t.Errorf("%s: expected the connection to be refused, got %v", target, err)
+		}
+	}
+	if called {
+		t.Errorf("Expected the loopback server not to be called")
+	}
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	allowWebhookIP = func(netip.Addr) bool { return true }
+	defer func() { allowWebhookIP = publicIP }()
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer hook.Close()
+
+	resp, err := webhookClient.Post(hook.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Expected the redirect not to be followed, got %v", resp.Status)
+	}
+}
+
+func TestSavedSearchPagesEscapeQueries(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	withTempSavedSearches(t)
+	payload := `"><script>alert(1)</script>`
+
+	alice := loggedIn(t, "alice")
+	rr := getWithCookie(SavedSearchesHandler, "/searches?query="+url.QueryEscape(payload), alice)
+	if body := rr.Body.String(); strings.Contains(body, "<script>alert(1)") || !strings.Contains(body, "&lt;script&gt;alert(1)") {
+		t.Errorf("Expected the query parameter to be escaped")
+	}
+
+	postForm(SavedSearchesHandler, "/searches", alice, url.Values{"action": {"save"}, "query": {payload}})
+	savedSearches.Deliver(map[string][]InboxEntry{"alice": {{Query: payload, Title: payload, ArtistID: 1}}})
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		target  string
+	}{
+		{SavedSearchesHandler, "/searches"},
+		{InboxHandler, "/inbox"},
+	} {
+		body := getWithCookie(page.handler, page.target, alice).Body.String()
+		if strings.Contains(body, "<script>alert(1)") || !strings.Contains(body, "&lt;script&gt;alert(1)") {
+			t.Errorf("%s: expected the saved query to be escaped", page.target)
+		}
+	}
+}
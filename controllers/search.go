@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+const (
+	maxSuggestions    = 5
+	maxPopularArtists = 4
+)
+
+// SearchMatch explains why an artist matched a search query: the field that
+// matched, the full value of that field and the byte span of the query in it
+type SearchMatch struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Suggestion is a search bar suggestion: a value found in the data and the kind of value it is
+type Suggestion struct {
+	Value    string `json:"value"`
+	Category string `json:"category"`
+}
+
+// ArtistResult is an artist returned by a search along with the reason it matched.
+// Match is nil when no query was given.
+type ArtistResult struct {
+	api.Artist
+	Match *SearchMatch `json:"match,omitempty"`
+	// Favorite is set on HTML pages for the artists the browser marked as favorite
+	Favorite bool `json:"-"`
+}
+
+// Before returns the part of the matched value preceding the match
+func (m SearchMatch) Before() string {
+	return m.Value[:m.Start]
+}
+
+// Text returns the part of the matched value that matched the query
+func (m SearchMatch) Text() string {
+	return m.Value[m.Start:m.End]
+}
+
+// After returns the part of the matched value following the match
+func (m SearchMatch) After() string {
+	return m.Value[m.End:]
+}
+
+// searchArtists filters the artists by the search query, answering qualified
+// queries such as "near:nairobi" or "location:kenya" from the concert data
+func searchArtists(artists []api.Artist, relations []api.Relation, query string) []ArtistResult {
+	if name, value, ok := parseQualifier(query); ok {
+		switch name {
+		case "near":
+			return searchNear(artists, relations, value)
+		case "member":
+			return searchMembers(artists, value)
+		case "location":
+			return searchLocations(artists, relations, value)
+		}
+	}
+	return filterArtists(artists, query)
+}
+
+// parseQualifier splits a qualified query such as "near:nairobi" into its
+// lowercase qualifier and value
+func parseQualifier(query string) (string, string, bool) {
+	name, value, ok := strings.Cut(strings.TrimSpace(query), ":")
+	value = strings.TrimSpace(value)
+	if !ok || value == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false
+	}
+	return strings.ToLower(name), value, true
+}
+
+// filterArtists filters the list of artists based on the search query
+func filterArtists(artists []api.Artist, query string) []ArtistResult {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		result := make([]ArtistResult, len(artists))
+		for i, a := range artists {
+			result[i] = ArtistResult{Artist: a}
+		}
+		return result
+	}
+
+	var result []ArtistResult
+	for _, a := range artists {
+		if match := matchArtist(a, query); match != nil {
+			result = append(result, ArtistResult{Artist: a, Match: match})
+		}
+	}
+	return result
+}
+
+// matchArtist returns the first field of the artist matching the query, checked in
+// order: name, members, first album date, creation date and locations
+func matchArtist(a api.Artist, query string) *SearchMatch {
+	// Artist/band name
+	if m := matchField("name", a.Name, query); m != nil {
+		return m
+	}
+
+	// Members
+	for _, member := range a.Members {
+		if m := matchField("member", member, query); m != nil {
+			return m
+		}
+	}
+
+	// First album date
+	if m := matchField("first album", a.FirstAlbum, query); m != nil {
+		return m
+	}
+
+	// Creation date
+	if m := matchField("creation date", strconv.Itoa(a.CreationDate), query); m != nil {
+		return m
+	}
+
+	// Locations
+	for _, loc := range strings.Split(a.Locations, ", ") {
+		if m := matchField("location", loc, query); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// matchField returns a match when value contains query, ignoring case
+func matchField(field, value, query string) *SearchMatch {
+	start, end := indexFold(value, query)
+	if start < 0 {
+		return nil
+	}
+	return &SearchMatch{Field: field, Value: value, Start: start, End: end}
+}
+
+// indexFold returns the byte span of the first case-insensitive occurrence of
+// substr in s, or -1, -1 if substr is not present
+func indexFold(s, substr string) (int, int) {
+	n := utf8.RuneCountInString(substr)
+	for i := range s {
+		j := i
+		for k := 0; k < n && j < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[j:])
+			j += size
+		}
+		if strings.EqualFold(s[i:j], substr) {
+			return i, j
+		}
+	}
+	return -1, -1
+}
+
+// suggestAlternatives returns up to limit artist names and members that are spelled
+// close to the query, closest first, for "did you mean" suggestions
+func suggestAlternatives(artists []api.Artist, query string, limit int) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	maxDistance := utf8.RuneCountInString(query) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	type candidate struct {
+		text     string
+		distance int
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	consider := func(text string) {
+		key := strings.ToLower(text)
+		if seen[key] {
+			return
+		}
+		// Compare against the whole text and each of its words so that
+		// "mercuri" still finds "Freddie Mercury"
+		best := levenshtein(query, key)
+		for _, word := range strings.Fields(key) {
+			if d := levenshtein(query, word); d < best {
+				best = d
+			}
+		}
+		if best <= maxDistance {
+			seen[key] = true
+			candidates = append(candidates, candidate{text, best})
+		}
+	}
+
+	for _, a := range artists {
+		consider(a.Name)
+		for _, member := range a.Members {
+			consider(member)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, candidates[i].text)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b, counted in runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// popularArtists returns the limit artists with the most concerts
+func popularArtists(artists []api.Artist, relations []api.Relation, limit int) []ArtistResult {
+	counts := make(map[int]int, len(relations))
+	for _, rel := range relations {
+		for _, dates := range rel.DatesLocations {
+			counts[rel.ID] += len(dates)
+		}
+	}
+
+	sorted := make([]api.Artist, len(artists))
+	copy(sorted, artists)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return counts[sorted[i].ID] > counts[sorted[j].ID]
+	})
+
+	var popular []ArtistResult
+	for i := 0; i < len(sorted) && i < limit; i++ {
+		popular = append(popular, ArtistResult{Artist: sorted[i]})
+	}
+	return popular
+}
+
+// searchSuggestions lists every artist/band, member, first album date, creation
+// date and location containing the query, ignoring case
+func searchSuggestions(artists []api.Artist, query string) []Suggestion {
+	query = strings.ToLower(query)
+	suggestions := []Suggestion{}
+	for _, artist := range artists {
+		// Artist/band name
+		if strings.Contains(strings.ToLower(artist.Name), query) {
+			suggestions = append(suggestions, Suggestion{artist.Name, "artist/band"})
+		}
+
+		// Members
+		for _, member := range artist.Members {
+			if strings.Contains(strings.ToLower(member), query) {
+				suggestions = append(suggestions, Suggestion{member, "member"})
+			}
+		}
+
+		// First album date
+		if strings.Contains(strings.ToLower(artist.FirstAlbum), query) {
+			suggestions = append(suggestions, Suggestion{artist.FirstAlbum, "first album date"})
+		}
+
+		// Creation date
+		if strings.Contains(strconv.Itoa(artist.CreationDate), query) {
+			suggestions = append(suggestions, Suggestion{strconv.Itoa(artist.CreationDate), "creation date"})
+		}
+
+		// Locations
+		for _, loc := range strings.Split(artist.Locations, ", ") {
+			if strings.Contains(strings.ToLower(loc), query) {
+				suggestions = append(suggestions, Suggestion{loc, "location"})
+			}
+		}
+	}
+	return suggestions
+}
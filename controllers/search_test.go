@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"testing"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+var searchTestArtists = []api.Artist{
+	{
+		ID:           1,
+		Name:         "Queen",
+		CreationDate: 1970,
+		FirstAlbum:   "14-12-1973",
+		Members:      []string{"Freddie Mercury", "Brian May"},
+		Locations:    "north_carolina-usa, osaka-japan",
+	},
+	{
+		ID:           2,
+		Name:         "Phil Collins",
+		CreationDate: 1975,
+		FirstAlbum:   "13-02-1981",
+		Members:      []string{"Phil Collins"},
+		Locations:    "berlin-germany, london-uk",
+	},
+}
+
+func TestFilterArtistsMatchMetadata(t *testing.T) {
+	tests := []struct {
+		query string
+		id    int
+		field string
+		value string
+		text  string
+	}{
+		{"queen", 1, "name", "Queen", "Queen"},
+		{"MERCURY", 1, "member", "Freddie Mercury", "Mercury"},
+		{"1981", 2, "first album", "13-02-1981", "1981"},
+		{"1970", 1, "creation date", "1970", "1970"},
+		{"germany", 2, "location", "berlin-germany", "germany"},
+	}
+
+	for _, tt := range tests {
+		results := filterArtists(searchTestArtists, tt.query)
+		if len(results) != 1 {
+			t.Fatalf("Query %q: expected 1 result, got %d", tt.query, len(results))
+		}
+		m := results[0].Match
+		if results[0].ID != tt.id || m == nil {
+			t.Fatalf("Query %q: expected artist %d with a match, got %+v", tt.query, tt.id, results[0])
+		}
+		if m.Field != tt.field || m.Value != tt.value || m.Text() != tt.text {
+			t.Errorf("Query %q: expected %s %q matching %q, got %s %q matching %q", tt.query, tt.field, tt.value, tt.text, m.Field, m.Value, m.Text())
+		}
+	}
+}
+
+func TestFilterArtistsNameBeforeMember(t *testing.T) {
+	results := filterArtists(searchTestArtists, "phil")
+	if len(results) != 1 {
+		t.Fatalf("Expected artist to be listed once, got %d results", len(results))
+	}
+	if results[0].Match.Field != "name" {
+		t.Errorf("Expected name match to win over member match, got %s", results[0].Match.Field)
+	}
+}
+
+func TestFilterArtistsEmptyQuery(t *testing.T) {
+	results := filterArtists(searchTestArtists, "  ")
+	if len(results) != len(searchTestArtists) {
+		t.Fatalf("Expected all artists, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Match != nil {
+			t.Errorf("Expected no match metadata for empty query, got %+v", r.Match)
+		}
+	}
+}
+
+func TestSearchMatchSpan(t *testing.T) {
+	m := matchField("member", "Freddie Mercury", "die merc")
+	if m == nil {
+		t.Fatalf("Expected a match, got nil")
+	}
+	if m.Before() != "Fred" || m.Text() != "die Merc" || m.After() != "ury" {
+		t.Errorf("Expected Fred|die Merc|ury, got %s|%s|%s", m.Before(), m.Text(), m.After())
+	}
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s, substr  string
+		start, end int
+	}{
+		{"Beyoncé", "CÉ", 5, 8},
+		{"Queen", "een", 2, 5},
+		{"Queen", "king", -1, -1},
+		{"", "a", -1, -1},
+	}
+	for _, tt := range tests {
+		start, end := indexFold(tt.s, tt.substr)
+		if start != tt.start || end != tt.end {
+			t.Errorf("indexFold(%q, %q): expected %d,%d got %d,%d", tt.s, tt.substr, tt.start, tt.end, start, end)
+		}
+	}
+}
+
+func TestSuggestAlternatives(t *testing.T) {
+	suggestions := suggestAlternatives(searchTestArtists, "mercuri", maxSuggestions)
+	if len(suggestions) == 0 || suggestions[0] != "Freddie Mercury" {
+		t.Errorf("Expected 'Freddie Mercury' to be suggested, got %v", suggestions)
+	}
+
+	if suggestions := suggestAlternatives(searchTestArtists, "xylophone", maxSuggestions); len(suggestions) != 0 {
+		t.Errorf("Expected no suggestions, got %v", suggestions)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"queen", "queen", 0},
+		{"quen", "queen", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if d := levenshtein(tt.a, tt.b); d != tt.distance {
+			t.Errorf("levenshtein(%q, %q): expected %d, got %d", tt.a, tt.b, tt.distance, d)
+		}
+	}
+}
+
+func TestPopularArtists(t *testing.T) {
+	relations := []api.Relation{
+		{ID: 1, DatesLocations: map[string][]string{"osaka-japan": {"28-01-2020"}}},
+		{ID: 2, DatesLocations: map[string][]string{"berlin-germany": {"01-01-2019", "02-01-2019"}}},
+	}
+	popular := popularArtists(searchTestArtists, relations, 1)
+	if len(popular) != 1 || popular[0].ID != 2 {
+		t.Errorf("Expected artist 2 to be the most popular, got %+v", popular)
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// sessionCookie names the cookie identifying a browser session
+const sessionCookie = "groupie_session"
+
+// sessionMaxAge keeps the session cookie, and so the favorites, for a year
+const sessionMaxAge = 365 * 24 * 60 * 60
+
+// csrfField is the name of the hidden form field holding the CSRF token
+const csrfField = "csrf_token"
+
+// csrfKey signs the CSRF tokens. It changes on every start, so forms opened before
+// a restart have to be reloaded.
+var csrfKey = newCSRFKey()
+
+func newCSRFKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Error generating the CSRF key: %v", err)
+	}
+	return key
+}
+
+// newSessionID returns a random session identifier
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validSessionID reports whether a cookie value looks like an ID from newSessionID
+func validSessionID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// setSessionCookie stores a session ID in the browser
+func setSessionCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   sessionMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// browserSession returns the ID of the browser session. When create is true and the
+// browser has none yet, a session cookie is set.
+func browserSession(w http.ResponseWriter, r *http.Request, create bool) (string, error) {
+	if c, err := r.Cookie(sessionCookie); err == nil && validSessionID(c.Value) {
+		return c.Value, nil
+	}
+	if !create {
+		return "", nil
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	setSessionCookie(w, id)
+	return id, nil
+}
+
+// pageSession returns the session of a page with forms and the CSRF token of its
+// forms, creating the session if needed. Errors are logged and leave the forms
+// without a token, so only submitting them fails.
+func pageSession(w http.ResponseWriter, r *http.Request) (string, string) {
+	session, err := browserSession(w, r, true)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		return "", ""
+	}
+	return session, csrfToken(session)
+}
+
+// csrfToken derives the CSRF token of a session
+func csrfToken(session string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte(session))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF reports whether a form was posted with the CSRF token of the session
+func checkCSRF(r *http.Request, session string) bool {
+	if session == "" {
+		return false
+	}
+	return hmac.Equal([]byte(r.PostFormValue(csrfField)), []byte(csrfToken(session)))
+}
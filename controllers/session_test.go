@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	a, _ := newSessionID()
+	b, _ := newSessionID()
+	if csrfToken(a) != csrfToken(a) || csrfToken(a) == csrfToken(b) {
+		t.Errorf("Expected a stable token per session")
+	}
+
+	post := func(token string) *http.Request {
+		form := url.Values{csrfField: {token}}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	if !checkCSRF(post(csrfToken(a)), a) {
+		t.Errorf("Expected the token of the session to be accepted")
+	}
+	if checkCSRF(post(csrfToken(b)), a) || checkCSRF(post(""), a) || checkCSRF(post(csrfToken("")), "") {
+		t.Errorf("Expected other tokens to be refused")
+	}
+}
+
+func TestBrowserSession(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	if id, _ := browserSession(rr, req, false); id != "" || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected no session to be created, got %q", id)
+	}
+	rr = httptest.NewRecorder()
+	id, err := browserSession(rr, req, true)
+	if err != nil || !validSessionID(id) || len(rr.Result().Cookies()) != 1 {
+		t.Fatalf("Expected a new session, got %q (%v)", id, err)
+	}
+
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+	rr = httptest.NewRecorder()
+	if got, _ := browserSession(rr, req, true); got != id || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected the session %q to be reused, got %q", id, got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "../../etc/passwd"})
+	if got, _ := browserSession(httptest.NewRecorder(), req, false); got != "" {
+		t.Errorf("Expected an invalid cookie to be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// maxSimilar is the number of similar artists kept per artist
+const maxSimilar = 5
+
+// Weights of the similarity criteria. They add up to 1, so scores range from 0 to 1.
+const (
+	locationWeight = 0.5
+	periodWeight   = 0.2
+	eraWeight      = 0.15
+	membersWeight  = 0.15
+)
+
+// eraSpan is the gap in creation years at which two artists stop being of the same era
+const eraSpan = 30
+
+// SimilarArtist is an artist recommended from another one, with the reasons why
+type SimilarArtist struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Image   string   `json:"image"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// similarityProfile holds what artists are compared on
+type similarityProfile struct {
+	artist    api.Artist
+	locations map[string]bool
+	// months are the "YYYY-MM" months the artist was touring
+	months map[string]bool
+}
+
+// jaccard returns the size of the intersection of two sets over the size of their union
+func jaccard(a, b map[string]bool) (float64, int) {
+	shared := 0
+	for key := range a {
+		if b[key] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0, 0
+	}
+	return float64(shared) / float64(union), shared
+}
+
+// closeness returns 1 for equal values, decreasing linearly to 0 at span apart
+func closeness(a, b, span int) float64 {
+	if span <= 0 {
+		return 0
+	}
+	diff := math.Abs(float64(a - b))
+	return math.Max(0, 1-diff/float64(span))
+}
+
+// similarity scores how alike two artists are. Shared concert locations and touring
+// months are compared with the Jaccard index, creation years and member counts by
+// how close they are. related is false when the artists share neither a location
+// nor a touring month.
+func similarity(a, b similarityProfile) (score float64, reasons []string, related bool) {
+	locations, sharedLocations := jaccard(a.locations, b.locations)
+	if sharedLocations > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d shared location(s)", sharedLocations))
+	}
+	period, sharedMonths := jaccard(a.months, b.months)
+	if sharedMonths > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d touring month(s) in common", sharedMonths))
+	}
+	era := closeness(a.artist.CreationDate, b.artist.CreationDate, eraSpan)
+	if math.Abs(float64(a.artist.CreationDate-b.artist.CreationDate)) < 10 {
+		reasons = append(reasons, "formed within 10 years of each other")
+	}
+	largest := len(a.artist.Members)
+	if len(b.artist.Members) > largest {
+		largest = len(b.artist.Members)
+	}
+	members := closeness(len(a.artist.Members), len(b.artist.Members), largest)
+	if len(a.artist.Members) == len(b.artist.Members) {
+		reasons = append(reasons, fmt.Sprintf("%d member(s) each", len(a.artist.Members)))
+	}
+
+	score = locationWeight*locations + periodWeight*period + eraWeight*era + membersWeight*members
+	return math.Round(score*1000) / 1000, reasons, sharedLocations > 0 || sharedMonths > 0
+}
+
+// buildSimilarArtists ranks, for every artist, the artists most similar to it. Era
+// and size alone do not make two artists similar, so only related artists are kept.
+func buildSimilarArtists(artists []api.Artist, relations []api.Relation) map[int][]SimilarArtist {
+	concerts := map[int][]Concert{}
+	for _, c := range buildConcerts(relations) {
+		concerts[c.ArtistID] = append(concerts[c.ArtistID], c)
+	}
+	profiles := make([]similarityProfile, len(artists))
+	for i, a := range artists {
+		p := similarityProfile{artist: a, locations: map[string]bool{}, months: map[string]bool{}}
+		for _, c := range concerts[a.ID] {
+			p.locations[c.Location] = true
+			p.months[c.Time.Format("2006-01")] = true
+		}
+		profiles[i] = p
+	}
+
+	similar := make(map[int][]SimilarArtist, len(artists))
+	for i, a := range profiles {
+		ranked := []SimilarArtist{}
+		for j, b := range profiles {
+			if i == j {
+				continue
+			}
+			score, reasons, related := similarity(a, b)
+			if !related {
+				continue
+			}
+			ranked = append(ranked, SimilarArtist{ID: b.artist.ID, Name: b.artist.Name, Image: b.artist.Image, Score: score, Reasons: reasons})
+		}
+		sort.SliceStable(ranked, func(x, y int) bool {
+			if ranked[x].Score != ranked[y].Score {
+				return ranked[x].Score > ranked[y].Score
+			}
+			return ranked[x].ID < ranked[y].ID
+		})
+		if len(ranked) > maxSimilar {
+			ranked = ranked[:maxSimilar]
+		}
+		similar[a.artist.ID] = ranked
+	}
+	return similar
+}
+
+// getSimilarArtists returns the artists most similar to an artist, computed on the
+// last refresh
+func getSimilarArtists(id int) []SimilarArtist {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	if similar, ok := similarCache[id]; ok {
+		return similar
+	}
+	return []SimilarArtist{}
+}
+
+// GetSimilarArtistsHandler handles the /api/v1/artists/{id}/similar route
+func GetSimilarArtistsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, apiPrefix+"/artists/"), "/similar")
+	artistID, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid artist ID")
+		return
+	}
+
+	artists, _, _, _ := getCachedData()
+	if _, ok := findArtist(artists, artistID); !ok {
+		writeJSONError(w, http.StatusNotFound, "artist not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, getSimilarArtists(artistID), nil)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJaccard(t *testing.T) {
+	a := map[string]bool{"x": true, "y": true, "z": true}
+	b := map[string]bool{"y": true, "z": true, "w": true}
+	if index, shared := jaccard(a, b); index != 0.5 || shared != 2 {
+		t.Errorf("Expected 0.5 with 2 shared, got %v with %d", index, shared)
+	}
+	if index, shared := jaccard(map[string]bool{}, map[string]bool{}); index != 0 || shared != 0 {
+		t.Errorf("Expected 0 for empty sets, got %v with %d", index, shared)
+	}
+}
+
+func TestBuildSimilarArtists(t *testing.T) {
+	similar := buildSimilarArtists(fixtureArtists, fixtureRelations)
+
+	// Queen only shares Los Angeles in August 2019 with Genesis
+	if len(similar[1]) != 1 || similar[1][0].Name != "Genesis" {
+		t.Errorf("Expected Genesis as the only artist similar to Queen, got %+v", similar[1])
+	}
+	if reasons := strings.Join(similar[1][0].Reasons, ", "); !strings.Contains(reasons, "1 shared location(s)") || !strings.Contains(reasons, "1 touring month(s) in common") {
+		t.Errorf("Expected the shared location and month as reasons, got %q", reasons)
+	}
+
+	// Pink Floyd and Phil Collins both played Berlin and London, Queen only Los Angeles
+	genesis := similar[4]
+	if len(genesis) != 3 || genesis[2].Name != "Queen" {
+		t.Fatalf("Expected Queen to be the least similar to Genesis, got %+v", genesis)
+	}
+	for i := 1; i < len(genesis); i++ {
+		if genesis[i].Score > genesis[i-1].Score {
+			t.Errorf("Expected artists by descending score, got %+v", genesis)
+		}
+	}
+	for _, s := range genesis {
+		if s.ID == 4 || s.Score <= 0 || s.Score > 1 {
+			t.Errorf("Unexpected recommendation %+v", s)
+		}
+	}
+
+	if len(similar[2]) != 1 || similar[2][0].Name != "Phil Collins" {
+		t.Errorf("Expected SOJA to be similar to Phil Collins through Nairobi, got %+v", similar[2])
+	}
+}
+
+func TestGetSimilarArtistsHandler(t *testing.T) {
+	rr := serveAPI(GetSimilarArtistsHandler, "GET", "/api/v1/artists/5/similar")
+	var similar []SimilarArtist
+	decodeAPIResponse(t, rr, &similar)
+	if len(similar) == 0 || similar[0].Name != "Genesis" {
+		t.Errorf("Expected Genesis to be the most similar to Phil Collins, got %+v", similar)
+	}
+
+	rr = serveAPI(GetSimilarArtistsHandler, "GET", "/api/v1/artists/999/similar")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+	rr = serveAPI(GetSimilarArtistsHandler, "GET", "/api/v1/artists/abc/similar")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+}
+
+func TestArtistDetailsShowsSimilarArtists(t *testing.T) {
+	rr := negotiated(ServeArtistDetails, "/artist/1", "text/html")
+	body := rr.Body.String()
+	if !strings.Contains(body, "Similar artists") || !strings.Contains(body, `<a href="/artist/4">`) {
+		t.Errorf("Expected the detail page to link the similar artists")
+	}
+}
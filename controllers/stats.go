@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// statsTopN is the length of the busiest cities and most prolific artists rankings
+const statsTopN = 10
+
+// StatCount is one row of a statistics table. Key links the row to its page: a
+// location or country slug, a year or an artist ID.
+type StatCount struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+	// Percent is the count relative to the largest of its table, for the bar charts
+	Percent int `json:"-"`
+}
+
+// CatalogStats summarises the cached data. It is computed once per cache refresh.
+type CatalogStats struct {
+	GeneratedAt     time.Time   `json:"generatedAt"`
+	Artists         int         `json:"artists"`
+	Concerts        int         `json:"concerts"`
+	AverageMembers  float64     `json:"averageMembers"`
+	Decades         []StatCount `json:"decades"`
+	Countries       []StatCount `json:"countries"`
+	BusiestCities   []StatCount `json:"busiestCities"`
+	BusiestYears    []StatCount `json:"busiestYears"`
+	ProlificArtists []StatCount `json:"prolificArtists"`
+}
+
+// statCounter counts rows by key and remembers their labels
+type statCounter struct {
+	counts map[string]int
+	labels map[string]string
+}
+
+func newStatCounter() *statCounter {
+	return &statCounter{counts: map[string]int{}, labels: map[string]string{}}
+}
+
+func (c *statCounter) add(key, label string) {
+	c.counts[key]++
+	c.labels[key] = label
+}
+
+// ranked lists the rows by descending count, then by key. A positive limit keeps
+// only the first rows.
+func (c *statCounter) ranked(limit int) []StatCount {
+	rows := c.rows()
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Count > rows[j].Count
+	})
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return withPercents(rows)
+}
+
+// rows lists the rows by key
+func (c *statCounter) rows() []StatCount {
+	rows := make([]StatCount, 0, len(c.counts))
+	for key, n := range c.counts {
+		rows = append(rows, StatCount{Key: key, Label: c.labels[key], Count: n})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+// withPercents sets the Percent of each row
+func withPercents(rows []StatCount) []StatCount {
+	largest := 0
+	for _, r := range rows {
+		if r.Count > largest {
+			largest = r.Count
+		}
+	}
+	for i := range rows {
+		if largest > 0 {
+			rows[i].Percent = rows[i].Count * 100 / largest
+		}
+	}
+	return rows
+}
+
+// buildCatalogStats computes the statistics of the artists and their concerts
+func buildCatalogStats(artists []api.Artist, relations []api.Relation, now time.Time) CatalogStats {
+	stats := CatalogStats{GeneratedAt: now, Artists: len(artists)}
+
+	decades := newStatCounter()
+	names := make(map[int]string, len(artists))
+	members := 0
+	for _, a := range artists {
+		names[a.ID] = a.Name
+		members += len(a.Members)
+		if a.CreationDate > 0 {
+			decade := strconv.Itoa(a.CreationDate/10*10) + "s"
+			decades.add(decade, decade)
+		}
+	}
+	if len(artists) > 0 {
+		stats.AverageMembers = math.Round(float64(members)/float64(len(artists))*100) / 100
+	}
+	stats.Decades = withPercents(decades.rows())
+
+	countries, cities, years, prolific := newStatCounter(), newStatCounter(), newStatCounter(), newStatCounter()
+	for _, c := range buildConcerts(relations) {
+		stats.Concerts++
+		countries.add(countrySlug(c.Country), formatCountry(c.Country))
+		cities.add(c.Location, formatLocation(c.Location))
+		year := strconv.Itoa(c.Time.Year())
+		years.add(year, year)
+		if name, ok := names[c.ArtistID]; ok {
+			prolific.add(strconv.Itoa(c.ArtistID), name)
+		}
+	}
+	stats.Countries = countries.ranked(0)
+	stats.BusiestCities = cities.ranked(statsTopN)
+	stats.BusiestYears = years.ranked(0)
+	stats.ProlificArtists = prolific.ranked(statsTopN)
+	return stats
+}
+
+// getCatalogStats returns the statistics computed on the last refresh
+func getCatalogStats() CatalogStats {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return catalogStats
+}
+
+// GetStatsHandler handles the /api/v1/stats route
+func GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	writeJSON(w, http.StatusOK, getCatalogStats(), nil)
+}
+
+// ServeStats handles the /stats page
+func ServeStats(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	renderTemplate(w, http.StatusOK, "templates/stats.html", getCatalogStats())
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildCatalogStats(t *testing.T) {
+	stats := buildCatalogStats(fixtureArtists, fixtureRelations, time.Now())
+	if stats.Artists != 5 || stats.Concerts != 22 {
+		t.Errorf("Expected 5 artists and 22 concerts, got %d and %d", stats.Artists, stats.Concerts)
+	}
+
+	members := 0
+	decades := map[string]int{}
+	for _, a := range fixtureArtists {
+		members += len(a.Members)
+		decades[strconv.Itoa(a.CreationDate/10*10)+"s"]++
+	}
+	if expected := float64(members) / 5; stats.AverageMembers < expected-0.01 || stats.AverageMembers > expected+0.01 {
+		t.Errorf("Expected %.2f members on average, got %v", expected, stats.AverageMembers)
+	}
+	if len(stats.Decades) != len(decades) {
+		t.Errorf("Expected %d decades, got %+v", len(decades), stats.Decades)
+	}
+	for i, d := range stats.Decades {
+		if d.Count != decades[d.Key] || (i > 0 && d.Key < stats.Decades[i-1].Key) {
+			t.Errorf("Expected decades in order with their counts, got %+v", stats.Decades)
+		}
+	}
+
+	if c := stats.Countries[0]; c.Key != "uk" || c.Label != "UK" || c.Count != 4 || c.Percent != 100 {
+		t.Errorf("Expected the UK first, ahead of the USA by name, got %+v", c)
+	}
+	if c := stats.BusiestCities[0]; c.Key != "london-uk" || c.Label != "London, UK" || c.Count != 4 {
+		t.Errorf("Expected London as the busiest city, got %+v", c)
+	}
+	if len(stats.BusiestYears) != 2 || stats.BusiestYears[0].Key != "2019" || stats.BusiestYears[0].Count != 16 {
+		t.Errorf("Expected 2019 as the busiest year, got %+v", stats.BusiestYears)
+	}
+	if a := stats.ProlificArtists[0]; a.Key != "1" || a.Label != "Queen" || a.Count != 8 {
+		t.Errorf("Expected Queen as the most prolific artist, got %+v", a)
+	}
+	if p := stats.ProlificArtists[len(stats.ProlificArtists)-1].Percent; p != 3*100/8 {
+		t.Errorf("Expected bars relative to the largest count, got %d%%", p)
+	}
+}
+
+func TestBuildCatalogStatsEmpty(t *testing.T) {
+	stats := buildCatalogStats(nil, nil, time.Now())
+	if stats.AverageMembers != 0 || stats.Decades == nil || stats.Countries == nil {
+		t.Errorf("Expected empty statistics, got %+v", stats)
+	}
+}
+
+func TestStatsEndpoints(t *testing.T) {
+	rr := serveAPI(GetStatsHandler, "GET", "/api/v1/stats")
+	var stats CatalogStats
+	decodeAPIResponse(t, rr, &stats)
+	if stats.Artists != 5 || len(stats.BusiestCities) == 0 {
+		t.Errorf("Expected the cached statistics, got %+v", stats)
+	}
+
+	rr = serveAPI(ServeStats, "GET", "/stats")
+	body := rr.Body.String()
+	if !strings.Contains(body, `<a href="/location/london-uk">London, UK</a>`) || !strings.Contains(body, `<a href="/artist/1">Queen</a>`) {
+		t.Errorf("Expected the stats page to link the busiest cities and artists")
+	}
+}
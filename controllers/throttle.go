@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Failed logins are free up to loginFreeFailures per client IP and per username.
+// Each further failure doubles the wait before the next attempt, from
+// loginBaseBackoff up to loginMaxBackoff. Failures are forgotten loginFailureTTL
+// after the last one.
+const (
+	loginFreeFailures = 5
+	loginBaseBackoff  = time.Second
+	loginMaxBackoff   = 15 * time.Minute
+	loginFailureTTL   = time.Hour
+)
+
+// loginFailures counts the failed logins of an IP or a username
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// loginLimiter slows down password guessing, by client and by account
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	pruned   time.Time
+}
+
+var loginAttempts = newLoginLimiter()
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: map[string]*loginFailures{}}
+}
+
+// loginKeys returns the keys under which the failures of a login are counted
+func loginKeys(r *http.Request, username string) []string {
+	keys := []string{"ip:" + clientIP(r)}
+	if name, err := normalizeUsername(username); err == nil {
+		keys = append(keys, "user:"+name)
+	}
+	return keys
+}
+
+// backoff is the wait imposed after count failures
+func backoff(count int) time.Duration {
+	if count < loginFreeFailures {
+		return 0
+	}
+	wait := loginBaseBackoff
+	for i := loginFreeFailures; i < count && wait < loginMaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, loginMaxBackoff)
+}
+
+// Wait returns how long the keys must wait before the next attempt, 0 if they may
+// try now
+func (l *loginLimiter) Wait(keys []string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var longest time.Duration
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok {
+			continue
+		}
+		if wait := f.last.Add(backoff(f.count)).Sub(now); wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+// Fail records a failed login of the keys
+func (l *loginLimiter) Fail(keys []string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || now.Sub(f.last) > loginFailureTTL {
+			f = &loginFailures{}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+	}
+}
+
+// Succeed forgets the failures of an account once its password was given. Those
+// of the IP are kept, so logging in to one account does not allow more guesses at
+// others.
+func (l *loginLimiter) Succeed(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, "user:"+username)
+}
+
+// prune drops the failures older than loginFailureTTL, at most once a minute. The
+// caller holds the lock.
+func (l *loginLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	for key, f := range l.failures {
+		if now.Sub(f.last) > loginFailureTTL {
+			delete(l.failures, key)
+		}
+	}
+}
+
+// clientIP returns the IP address a request comes from. Proxy headers are not
+// trusted, since any client can send them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter rounds a wait up to whole seconds, as the Retry-After header counts them
+func retryAfter(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// retryMessage tells how long to wait before logging in again
+func retryMessage(wait time.Duration) string {
+	if wait > time.Minute {
+		return fmt.Sprintf("Too many failed logins. Please try again in %d minutes.", int(wait.Round(time.Minute)/time.Minute))
+	}
+	return fmt.Sprintf("Too many failed logins. Please try again in %d seconds.", retryAfter(wait))
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTempLoginLimiter gives one test a limiter without recorded failures
+func withTempLoginLimiter(t *testing.T) {
+	t.Helper()
+	previous := loginAttempts
+	loginAttempts = newLoginLimiter()
+	t.Cleanup(func() { loginAttempts = previous })
+}
+
+// postLogin submits the login form from a client IP
+func postLogin(cookie *http.Cookie, ip, username, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "password": {password}, csrfField: {csrfToken(cookie.Value)}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":40000"
+	req.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+	LoginHandler(rr, req)
+	return rr
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		0:                     0,
+		loginFreeFailures - 1: 0,
+		loginFreeFailures:     loginBaseBackoff,
+		loginFreeFailures + 1: 2 * loginBaseBackoff,
+		loginFreeFailures + 3: 8 * loginBaseBackoff,
+		1000:                  loginMaxBackoff,
+	}
+	for count, expected := range tests {
+		if got := backoff(count); got != expected {
+			t.Errorf("backoff(%d): expected %v, got %v", count, expected, got)
+		}
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	keys := []string{"ip:203.0.113.7", "user:alice"}
+	for i := 0; i < loginFreeFailures; i++ {
+		if wait := l.Wait(keys, now); wait != 0 {
+			t.Fatalf("Expected failure %d to be free, got a wait of %v", i+1, wait)
+		}
+		l.Fail(keys, now)
+	}
+
+	if wait := l.Wait(keys, now); wait != loginBaseBackoff {
+		t.Errorf("Expected a wait of %v, got %v", loginBaseBackoff, wait)
+	}
+	if wait := l.Wait([]string{"ip:203.0.113.7", "user:bob"}, now); wait == 0 {
+		t.Errorf("Expected the IP to wait for any account")
+	}
+	if wait := l.Wait([]string{"ip:198.51.100.1", "user:alice"}, now); wait == 0 {
+		t.Errorf("Expected the account to wait from any IP")
+	}
+	if wait := l.Wait(keys, now.Add(loginBaseBackoff)); wait != 0 {
+		t.Errorf("Expected the wait to be over, got %v", wait)
+	}
+
+	// The next failure doubles the wait
+	now = now.Add(loginBaseBackoff)
+	l.Fail(keys, now)
+	if wait := l.Wait(keys, now); wait != 2*loginBaseBackoff {
+		t.Errorf("Expected a wait of %v, got %v", 2*loginBaseBackoff, wait)
+	}
+
+	// A login forgets the failures of the account, not those of the IP
+	l.Succeed("alice")
+	if wait := l.Wait([]string{"user:alice"}, now); wait != 0 {
+		t.Errorf("Expected the account failures to be forgotten, got %v", wait)
+	}
+	if wait := l.Wait([]string{"ip:203.0.113.7"}, now); wait == 0 {
+		t.Errorf("Expected the IP failures to be kept")
+	}
+
+	// Old failures are forgotten
+	now = now.Add(loginFailureTTL + time.Minute)
+	l.Fail(keys, now)
+	if wait := l.Wait(keys, now); wait != 0 {
+		t.Errorf("Expected the count to start over after %v, got a wait of %v", loginFailureTTL, wait)
+	}
+	if _, ok := l.failures["ip:203.0.113.7"]; !ok || len(l.failures) != 2 {
+		t.Errorf("Expected only the current failures to be kept, got %v", l.failures)
+	}
+}
+
+func TestLoginHandlerThrottles(t *testing.T) {
+	withTempAccounts(t)
+	withTempFavorites(t)
+	withTempLoginLimiter(t)
+	accounts.Register("alice", "s3cret-pass")
+	accounts.Register("bob", "s3cret-pass")
+	session := newBrowser(t)
+
+	for i := 0; i < loginFreeFailures; i++ {
+		if rr := postLogin(session, "203.0.113.7", "alice", "wrong-pass"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status Unauthorized; got %v", rr.Code)
+		}
+	}
+
+	// Even the right password waits, so guesses cannot be confirmed
+	rr := postLogin(session, "203.0.113.7", "alice", "s3cret-pass")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected status Too Many Requests with Retry-After; got %v %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rr.Body.String(), "Too many failed logins") {
+		t.Errorf("Expected the page to explain the wait")
+	}
+	if rr := postLogin(session, "203.0.113.7", "bob", "s3cret-pass"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the IP to be throttled for other accounts; got %v", rr.Code)
+	}
+	if rr := postLogin(session, "198.51.100.1", "alice", "s3cret-pass"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the account to be throttled from other IPs; got %v", rr.Code)
+	}
+	if rr := postLogin(session, "198.51.100.1", "bob", "s3cret-pass"); rr.Code != http.StatusSeeOther {
+		t.Errorf("Expected other accounts to log in from other IPs; got %v", rr.Code)
+	}
+}
+
+func TestRetryMessage(t *testing.T) {
+	if msg := retryMessage(1500 * time.Millisecond); !strings.Contains(msg, "2 seconds") {
+		t.Errorf("Expected the wait in seconds, got %q", msg)
+	}
+	if msg := retryMessage(8 * time.Minute); !strings.Contains(msg, "8 minutes") {
+		t.Errorf("Expected the wait in minutes, got %q", msg)
+	}
+}
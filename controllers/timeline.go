@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+)
+
+// TimelineConcert is a concert with the name of its artist
+type TimelineConcert struct {
+	Concert
+	Artist string `json:"artist"`
+}
+
+// TimelineMonth holds the concerts of one month and the artists touring then
+type TimelineMonth struct {
+	Month    int               `json:"month"`
+	Name     string            `json:"name"`
+	Artists  []string          `json:"artists"`
+	Concerts []TimelineConcert `json:"concerts"`
+}
+
+// TimelineYear holds the months of a year that had concerts
+type TimelineYear struct {
+	Year     int             `json:"year"`
+	Concerts int             `json:"concerts"`
+	Months   []TimelineMonth `json:"months"`
+}
+
+type TimelinePageData struct {
+	Years    []int
+	Current  TimelineYear
+	Year     int
+	Previous int
+	Next     int
+}
+
+// buildTimeline groups the concerts by year and month, both in chronological order
+func buildTimeline(artists []api.Artist, relations []api.Relation) []TimelineYear {
+	names := make(map[int]string, len(artists))
+	for _, a := range artists {
+		names[a.ID] = a.Name
+	}
+
+	var years []TimelineYear
+	for _, c := range buildConcerts(relations) {
+		if len(years) == 0 || years[len(years)-1].Year != c.Time.Year() {
+			years = append(years, TimelineYear{Year: c.Time.Year()})
+		}
+		year := &years[len(years)-1]
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != int(c.Time.Month()) {
+			year.Months = append(year.Months, TimelineMonth{Month: int(c.Time.Month()), Name: c.Time.Month().String()})
+		}
+		month := &year.Months[len(year.Months)-1]
+		month.Concerts = append(month.Concerts, TimelineConcert{Concert: c, Artist: names[c.ArtistID]})
+		year.Concerts++
+	}
+
+	for y := range years {
+		for m := range years[y].Months {
+			month := &years[y].Months[m]
+			seen := map[string]bool{}
+			for _, c := range month.Concerts {
+				if !seen[c.Artist] {
+					seen[c.Artist] = true
+					month.Artists = append(month.Artists, c.Artist)
+				}
+			}
+			sort.Strings(month.Artists)
+		}
+	}
+	return years
+}
+
+// timelineYears lists the years of the timeline
+func timelineYears(timeline []TimelineYear) []int {
+	years := make([]int, len(timeline))
+	for i, y := range timeline {
+		years[i] = y.Year
+	}
+	return years
+}
+
+// GetTimelineHandler handles the /api/v1/timeline route. A year parameter limits
+// the timeline to that year.
+func GetTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	initCache()
+	year, err := parseIntParam(r.URL.Query(), "year")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	artists, _, _, relations := getCachedData()
+	timeline := buildTimeline(artists, relations)
+	result := []TimelineYear{}
+	for _, y := range timeline {
+		if year == 0 || y.Year == year {
+			result = append(result, y)
+		}
+	}
+	writeJSON(w, http.StatusOK, result, nil)
+}
+
+// ServeTimeline handles the /timeline page, showing one year at a time. The latest
+// year is shown unless a year parameter is given.
+func ServeTimeline(w http.ResponseWriter, r *http.Request) {
+	initCache()
+	artists, _, _, relations := getCachedData()
+	timeline := buildTimeline(artists, relations)
+	data := TimelinePageData{Years: timelineYears(timeline)}
+
+	if s := r.URL.Query().Get("year"); s != "" {
+		year, err := strconv.Atoi(s)
+		if err != nil {
+			ErrorHandler(w, "Invalid year", http.StatusBadRequest, true, true)
+			return
+		}
+		data.Year = year
+	} else if len(timeline) > 0 {
+		data.Year = timeline[len(timeline)-1].Year
+	} else {
+		data.Year = time.Now().Year()
+	}
+
+	data.Current = TimelineYear{Year: data.Year}
+	for _, y := range timeline {
+		if y.Year == data.Year {
+			data.Current = y
+		}
+		if y.Year < data.Year {
+			data.Previous = y.Year
+		}
+		if y.Year > data.Year && data.Next == 0 {
+			data.Next = y.Year
+		}
+	}
+
+	renderTemplate(w, http.StatusOK, "templates/timeline.html", data)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	timeline := buildTimeline(fixtureArtists, fixtureRelations)
+	years := timelineYears(timeline)
+	if len(years) != 2 || years[0] != 2019 || years[1] != 2020 {
+		t.Fatalf("Expected 2019 and 2020, got %v", years)
+	}
+
+	y2019 := timeline[0]
+	if y2019.Months[0].Name != "January" || y2019.Months[0].Concerts[0].Artist != "Queen" {
+		t.Errorf("Expected Queen in Nagoya to open 2019, got %+v", y2019.Months[0])
+	}
+	total := 0
+	for _, y := range timeline {
+		total += y.Concerts
+	}
+	if total != len(buildConcerts(fixtureRelations)) {
+		t.Errorf("Expected every concert in the timeline, got %d", total)
+	}
+
+	for _, m := range y2019.Months {
+		if m.Name == "October" {
+			if len(m.Artists) != 2 || m.Artists[0] != "Genesis" || m.Artists[1] != "Phil Collins" {
+				t.Errorf("Expected Genesis and Phil Collins touring in October 2019, got %v", m.Artists)
+			}
+			if len(m.Concerts) != 4 {
+				t.Errorf("Expected 4 concerts in October 2019, got %d", len(m.Concerts))
+			}
+		}
+	}
+}
+
+func TestGetTimelineHandler(t *testing.T) {
+	rr := serveAPI(GetTimelineHandler, "GET", "/api/v1/timeline?year=2020")
+	var years []TimelineYear
+	decodeAPIResponse(t, rr, &years)
+	if len(years) != 1 || years[0].Year != 2020 || years[0].Months[0].Concerts[0].Location != "saitama-japan" {
+		t.Errorf("Expected only 2020, starting in Saitama, got %+v", years)
+	}
+
+	rr = serveAPI(GetTimelineHandler, "GET", "/api/v1/timeline")
+	decodeAPIResponse(t, rr, &years)
+	if len(years) != 2 {
+		t.Errorf("Expected every year without a year parameter, got %d", len(years))
+	}
+
+	rr = serveAPI(GetTimelineHandler, "GET", "/api/v1/timeline?year=last")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status Bad Request; got %v", rr.Code)
+	}
+}
+
+func TestServeTimeline(t *testing.T) {
+	rr := serveAPI(ServeTimeline, "GET", "/timeline")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "touring in 2020") || !strings.Contains(body, `href="/timeline?year=2019"`) {
+		t.Errorf("Expected the latest year with a link to the previous one")
+	}
+
+	rr = serveAPI(ServeTimeline, "GET", "/timeline?year=1999")
+	if !strings.Contains(rr.Body.String(), "No concerts were found in 1999") {
+		t.Errorf("Expected an empty year notice")
+	}
+}
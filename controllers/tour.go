@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/geo"
+)
+
+// TourStop is a geocoded concert, numbered in tour order
+type TourStop struct {
+	Concert
+	geo.Coordinates
+	Order int    `json:"order"`
+	Name  string `json:"name"`
+}
+
+// GeoJSON types, see RFC 7946
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// buildTourStops geocodes chronologically sorted concerts into tour stops. Concerts
+// at locations that cannot be geocoded are left out.
+func buildTourStops(concerts []Concert) []TourStop {
+	stops := []TourStop{}
+	for _, c := range concerts {
+		coords, ok := geocoder.Lookup(c.Location)
+		if !ok {
+			continue
+		}
+		stops = append(stops, TourStop{Concert: c, Coordinates: coords, Order: len(stops) + 1, Name: formatLocation(c.Location)})
+	}
+	return stops
+}
+
+// tourGeoJSON describes the stops as points and the tour path as a line between them.
+// GeoJSON positions are longitude first.
+func tourGeoJSON(artistName string, stops []TourStop) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	path := make([][]float64, 0, len(stops))
+	for _, s := range stops {
+		position := []float64{s.Lon, s.Lat}
+		path = append(path, position)
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: GeoJSONGeometry{Type: "Point", Coordinates: position},
+			Properties: map[string]interface{}{
+				"order":    s.Order,
+				"date":     s.Date,
+				"location": s.Location,
+				"name":     s.Name,
+			},
+		})
+	}
+	if len(path) > 1 {
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:       "Feature",
+			Geometry:   GeoJSONGeometry{Type: "LineString", Coordinates: path},
+			Properties: map[string]interface{}{"name": artistName + " tour"},
+		})
+	}
+	return collection
+}
+
+// ArtistGeoJSONHandler handles the /artist/{id}/concerts.geojson route
+func ArtistGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/artist/"), "/concerts.geojson")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid artist ID")
+		return
+	}
+
+	initCache()
+	artists, _, _, relations := getCachedData()
+	artist, ok := findArtist(artists, id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "artist not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	if err := json.NewEncoder(w).Encode(tourGeoJSON(artist.Name, buildTourStops(artistConcerts(relations, id)))); err != nil {
+		log.Printf("Error encoding GeoJSON: %v", err)
+	}
+}
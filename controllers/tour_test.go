@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBuildTourStops(t *testing.T) {
+	stops := buildTourStops(artistConcerts(fixtureRelations, 3))
+	if len(stops) != 4 {
+		t.Fatalf("Expected 4 Pink Floyd stops, got %d", len(stops))
+	}
+	if stops[0].Order != 1 || stops[0].Name != "London, UK" || stops[0].Date != "2019-04-14" || stops[0].Lat < 51 || stops[0].Lat > 52 {
+		t.Errorf("Expected London first, got %+v", stops[0])
+	}
+	if stops[3].Location != "berlin-germany" || stops[3].Order != 4 {
+		t.Errorf("Expected Berlin last, got %+v", stops[3])
+	}
+
+	concerts := append(artistConcerts(fixtureRelations, 3), Concert{ArtistID: 3, Location: "atlantis-ocean", Date: "2019-05-01"})
+	if stops := buildTourStops(concerts); len(stops) != 4 {
+		t.Errorf("Expected locations without coordinates to be left out, got %d stops", len(stops))
+	}
+}
+
+func TestArtistGeoJSONHandler(t *testing.T) {
+	rr := serveAPI(ArtistGeoJSONHandler, "GET", "/artist/5/concerts.geojson")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Expected GeoJSON, got %q", ct)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&collection); err != nil {
+		t.Fatalf("Invalid GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 4 {
+		t.Fatalf("Expected 3 points and a line, got %+v", collection)
+	}
+
+	var berlin []float64
+	json.Unmarshal(collection.Features[0].Geometry.Coordinates, &berlin)
+	if berlin[0] < 13 || berlin[0] > 14 || berlin[1] < 52 || berlin[1] > 53 {
+		t.Errorf("Expected Berlin as [lon, lat], got %v", berlin)
+	}
+	line := collection.Features[3]
+	var path [][]float64
+	json.Unmarshal(line.Geometry.Coordinates, &path)
+	if line.Geometry.Type != "LineString" || len(path) != 3 {
+		t.Errorf("Expected the tour path through 3 stops, got %+v", line)
+	}
+
+	if rr := serveAPI(ArtistGeoJSONHandler, "GET", "/artist/999/concerts.geojson"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status Not Found; got %v", rr.Code)
+	}
+}
+
+func TestServeArtistDetailsMap(t *testing.T) {
+	rr := serveAPI(ServeArtistDetails, "GET", "/artist/2")
+	body := rr.Body.String()
+	if !strings.Contains(body, `id="tour-map"`) || !strings.Contains(body, `"location":"nairobi-kenya"`) {
+		t.Errorf("Expected the page to embed the tour stops for the map")
+	}
+}
+
+func TestArtistPageTourStopsJSON(t *testing.T) {
+	body := serveAPI(ServeArtistDetails, "GET", "/artist/3").Body.String()
+	start := strings.Index(body, `<script id="tour-stops" type="application/json">`)
+	if start < 0 {
+		t.Fatalf("Expected the tour stops script block")
+	}
+	block := body[start:]
+	block = block[strings.Index(block, ">")+1 : strings.Index(block, "</script>")]
+
+	var stops []TourStop
+	if err := json.Unmarshal([]byte(block), &stops); err != nil || len(stops) != 4 {
+		t.Errorf("Expected the 4 stops as JSON for the map, got %q (%v)", block, err)
+	}
+
+	// The map library is loaded from a CDN, so it must be pinned with subresource integrity
+	for _, file := range []string{"leaflet.css", "leaflet.js"} {
+		i := strings.Index(body, "/dist/"+file+`"`)
+		if i < 0 || !strings.Contains(body[i:i+150], `integrity="sha256-`) {
+			t.Errorf("Expected %s to be loaded with an integrity hash", file)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"sort"
+
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/api"
+	"learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker/geo"
+)
+
+// unknownContinent groups concerts in countries missing from the continent table
+const unknownContinent = "Unknown"
+
+// TourLeg is the journey between two consecutive tour stops
+type TourLeg struct {
+	From       string  `json:"from"`
+	FromDate   string  `json:"fromDate"`
+	To         string  `json:"to"`
+	ToDate     string  `json:"toDate"`
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// ContinentConcerts is the number of concerts an artist played on a continent
+type ContinentConcerts struct {
+	Continent string `json:"continent"`
+	Concerts  int    `json:"concerts"`
+}
+
+// TourStats summarises the travels of an artist. Distances only cover the concerts
+// that could be geocoded.
+type TourStats struct {
+	Concerts   int                 `json:"concerts"`
+	DistanceKm float64             `json:"distanceKm"`
+	Countries  []string            `json:"countries"`
+	LongestLeg *TourLeg            `json:"longestLeg,omitempty"`
+	Continents []ContinentConcerts `json:"continents"`
+}
+
+// buildTourStats computes the statistics of chronologically sorted concerts
+func buildTourStats(concerts []Concert) TourStats {
+	stats := TourStats{Concerts: len(concerts), Countries: []string{}, Continents: []ContinentConcerts{}}
+
+	stops := buildTourStops(concerts)
+	for i := 1; i < len(stops); i++ {
+		from, to := stops[i-1], stops[i]
+		km := geo.Distance(from.Coordinates, to.Coordinates)
+		stats.DistanceKm += km
+		if stats.LongestLeg == nil || km > stats.LongestLeg.DistanceKm {
+			stats.LongestLeg = &TourLeg{From: from.Location, FromDate: from.Date, To: to.Location, ToDate: to.Date, DistanceKm: roundKm(km)}
+		}
+	}
+	stats.DistanceKm = roundKm(stats.DistanceKm)
+
+	countries := map[string]bool{}
+	perContinent := map[string]int{}
+	for _, c := range concerts {
+		if !countries[c.Country] {
+			countries[c.Country] = true
+			stats.Countries = append(stats.Countries, formatCountry(c.Country))
+		}
+		continent, ok := geo.Continent(c.Country)
+		if !ok {
+			continent = unknownContinent
+		}
+		perContinent[continent]++
+	}
+	sort.Strings(stats.Countries)
+
+	for continent, n := range perContinent {
+		stats.Continents = append(stats.Continents, ContinentConcerts{Continent: continent, Concerts: n})
+	}
+	sort.Slice(stats.Continents, func(i, j int) bool {
+		if stats.Continents[i].Concerts != stats.Continents[j].Concerts {
+			return stats.Continents[i].Concerts > stats.Continents[j].Concerts
+		}
+		return stats.Continents[i].Continent < stats.Continents[j].Continent
+	})
+	return stats
+}
+
+// buildAllTourStats computes the tour statistics of every artist, by artist ID
+func buildAllTourStats(relations []api.Relation) map[int]TourStats {
+	all := make(map[int]TourStats, len(relations))
+	for _, rel := range relations {
+		all[rel.ID] = buildTourStats(buildConcerts([]api.Relation{rel}))
+	}
+	return all
+}
+
+// getTourStats returns the tour statistics of an artist computed on the last refresh
+func getTourStats(id int) TourStats {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	if stats, ok := tourStatsCache[id]; ok {
+		return stats
+	}
+	return TourStats{Countries: []string{}, Continents: []ContinentConcerts{}}
+}
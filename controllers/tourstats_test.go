@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTourStats(t *testing.T) {
+	stats := buildTourStats(artistConcerts(fixtureRelations, 5))
+	if stats.Concerts != 3 {
+		t.Errorf("Expected 3 concerts, got %d", stats.Concerts)
+	}
+	if strings.Join(stats.Countries, ",") != "Germany,Kenya,UK" {
+		t.Errorf("Expected the countries in name order, got %v", stats.Countries)
+	}
+
+	// Berlin to London is about 930 km, London to Nairobi about 6800 km
+	if stats.DistanceKm < 7600 || stats.DistanceKm > 7900 {
+		t.Errorf("Expected about 7750 km travelled, got %v", stats.DistanceKm)
+	}
+	leg := stats.LongestLeg
+	if leg == nil || leg.From != "london-uk" || leg.To != "nairobi-kenya" || leg.ToDate != "2020-03-05" {
+		t.Errorf("Expected London to Nairobi as the longest leg, got %+v", leg)
+	}
+
+	if len(stats.Continents) != 2 || stats.Continents[0] != (ContinentConcerts{Continent: "Europe", Concerts: 2}) ||
+		stats.Continents[1] != (ContinentConcerts{Continent: "Africa", Concerts: 1}) {
+		t.Errorf("Expected 2 concerts in Europe and 1 in Africa, got %+v", stats.Continents)
+	}
+}
+
+func TestBuildTourStatsWithoutTravel(t *testing.T) {
+	stats := buildTourStats(nil)
+	if stats.DistanceKm != 0 || stats.LongestLeg != nil || len(stats.Countries) != 0 || stats.Continents == nil {
+		t.Errorf("Expected empty statistics, got %+v", stats)
+	}
+
+	stats = buildTourStats([]Concert{{Location: "atlantis-ocean", Country: "ocean"}})
+	if len(stats.Continents) != 1 || stats.Continents[0].Continent != unknownContinent {
+		t.Errorf("Expected an unknown continent, got %+v", stats.Continents)
+	}
+}
+
+func TestArtistDetailTourStats(t *testing.T) {
+	rr := serveAPI(GetArtistByIDHandler, "GET", "/api/v1/artists/1")
+	var artist ArtistDetail
+	decodeAPIResponse(t, rr, &artist)
+	stats := artist.TourStats
+	if stats.Concerts != 8 || len(stats.Countries) != 3 || stats.LongestLeg == nil {
+		t.Errorf("Expected the tour statistics of Queen, got %+v", stats)
+	}
+	if len(stats.Continents) != 3 || stats.Continents[0].Continent != "Asia" {
+		t.Errorf("Expected Asia to lead the continents of Queen, got %+v", stats.Continents)
+	}
+
+	rr = negotiated(ServeArtistDetails, "/artist/5", "text/html")
+	if body := rr.Body.String(); !strings.Contains(body, "Longest leg: London, UK (2019-10-15) to Nairobi, Kenya (2020-03-05)") {
+		t.Errorf("Expected the detail page to show the longest leg")
+	}
+}
@@ -0,0 +1,48 @@
+package geo
+
+// Continents in the order they are listed
+const (
+	Africa       = "Africa"
+	Asia         = "Asia"
+	Europe       = "Europe"
+	NorthAmerica = "North America"
+	SouthAmerica = "South America"
+	Oceania      = "Oceania"
+)
+
+// continents maps the country part of location keys to their continent. Central
+// America and the Caribbean count as North America.
+var continents = map[string]string{
+	"egypt": Africa, "kenya": Africa, "morocco": Africa, "nigeria": Africa, "south_africa": Africa,
+	"tanzania": Africa, "uganda": Africa, "ghana": Africa, "ethiopia": Africa, "tunisia": Africa,
+
+	"china": Asia, "india": Asia, "indonesia": Asia, "israel": Asia, "japan": Asia,
+	"malaysia": Asia, "philippines": Asia, "qatar": Asia, "singapore": Asia, "south_korea": Asia,
+	"taiwan": Asia, "thailand": Asia, "united_arab_emirates": Asia, "vietnam": Asia, "hong_kong": Asia,
+	"saudi_arabia": Asia, "lebanon": Asia,
+
+	"austria": Europe, "belarus": Europe, "belgium": Europe, "bulgaria": Europe, "croatia": Europe,
+	"czech_republic": Europe, "denmark": Europe, "estonia": Europe, "finland": Europe, "france": Europe,
+	"germany": Europe, "greece": Europe, "hungary": Europe, "iceland": Europe, "ireland": Europe,
+	"italy": Europe, "latvia": Europe, "lithuania": Europe, "luxembourg": Europe, "netherlands": Europe,
+	"norway": Europe, "poland": Europe, "portugal": Europe, "romania": Europe, "russia": Europe,
+	"serbia": Europe, "slovakia": Europe, "slovenia": Europe, "spain": Europe, "sweden": Europe,
+	"switzerland": Europe, "turkey": Europe, "uk": Europe, "ukraine": Europe,
+
+	"canada": NorthAmerica, "costa_rica": NorthAmerica, "mexico": NorthAmerica, "panama": NorthAmerica,
+	"usa": NorthAmerica, "cuba": NorthAmerica, "puerto_rico": NorthAmerica, "guatemala": NorthAmerica,
+
+	"argentina": SouthAmerica, "bolivia": SouthAmerica, "brazil": SouthAmerica, "chile": SouthAmerica,
+	"colombia": SouthAmerica, "ecuador": SouthAmerica, "paraguay": SouthAmerica, "peru": SouthAmerica,
+	"uruguay": SouthAmerica, "venezuela": SouthAmerica,
+
+	"australia": Oceania, "french_polynesia": Oceania, "new_caledonia": Oceania, "new_zealand": Oceania,
+	"fiji": Oceania,
+}
+
+// Continent returns the continent of a country as written in location keys
+// ("new_zealand") or as a display name ("New Zealand")
+func Continent(country string) (string, bool) {
+	c, ok := continents[Normalize(country)]
+	return c, ok
+}
@@ -0,0 +1,41 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContinent(t *testing.T) {
+	tests := map[string]string{
+		"usa":              NorthAmerica,
+		"new_zealand":      Oceania,
+		"New Zealand":      Oceania,
+		"UK":               Europe,
+		"french_polynesia": Oceania,
+		"kenya":            Africa,
+		"peru":             SouthAmerica,
+		"japan":            Asia,
+	}
+	for country, expected := range tests {
+		if continent, ok := Continent(country); !ok || continent != expected {
+			t.Errorf("%s: expected %s, got %q (%v)", country, expected, continent, ok)
+		}
+	}
+
+	if _, ok := Continent("atlantis"); ok {
+		t.Errorf("Expected an unknown country not to have a continent")
+	}
+}
+
+func TestContinentCoversGazetteer(t *testing.T) {
+	g, err := New()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for key := range g.places {
+		country := key[strings.LastIndex(key, "-")+1:]
+		if _, ok := Continent(country); !ok {
+			t.Errorf("Expected a continent for %s of %s", country, key)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package geo
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed gazetteer.csv
+var gazetteer string
+
+// Coordinates is a point on Earth in decimal degrees
+type Coordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Miss is a location the geocoder could not resolve, with the number of lookups
+type Miss struct {
+	Location string `json:"location"`
+	Count    int    `json:"count"`
+}
+
+// Geocoder resolves locations offline from the embedded gazetteer and optional overrides
+type Geocoder struct {
+	mu     sync.RWMutex
+	places map[string]Coordinates
+	misses map[string]int
+}
+
+// accents maps the accented letters found in place names to plain ASCII
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ß", "ss", "æ", "ae",
+)
+
+// Normalize turns a location into the "city-country" key of the gazetteer. It accepts
+// API locations ("north_carolina-usa") as well as display names ("North Carolina, USA").
+func Normalize(location string) string {
+	s := accents.Replace(strings.ToLower(strings.TrimSpace(location)))
+	if i := strings.LastIndex(s, ","); i >= 0 {
+		s = strings.TrimSpace(s[:i]) + "-" + strings.TrimSpace(s[i+1:])
+	}
+	s = strings.Join(strings.Fields(s), "_")
+	return strings.ReplaceAll(s, "_-_", "-")
+}
+
+// New returns a geocoder loaded with the embedded gazetteer
+func New() (*Geocoder, error) {
+	g := &Geocoder{places: map[string]Coordinates{}, misses: map[string]int{}}
+	if err := g.load(strings.NewReader(gazetteer)); err != nil {
+		return nil, fmt.Errorf("failed to load gazetteer: %v", err)
+	}
+	return g, nil
+}
+
+// LoadOverrides adds the places of a CSV file in the gazetteer format, replacing
+// gazetteer entries with the same key. A missing file is not an error.
+func (g *Geocoder) LoadOverrides(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open overrides: %v", err)
+	}
+	defer f.Close()
+	if err := g.load(f); err != nil {
+		return fmt.Errorf("failed to load overrides from %s: %v", path, err)
+	}
+	return nil
+}
+
+// load reads "location,latitude,longitude" rows. Lines starting with # and the
+// header row are skipped.
+func (g *Geocoder) load(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+
+	places := map[string]Coordinates{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if record[0] == "location" {
+			continue
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return fmt.Errorf("invalid latitude %q for %s", record[1], record[0])
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return fmt.Errorf("invalid longitude %q for %s", record[2], record[0])
+		}
+		places[Normalize(record[0])] = Coordinates{Lat: lat, Lon: lon}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, c := range places {
+		g.places[key] = c
+		delete(g.misses, key)
+	}
+	return nil
+}
+
+// Lookup resolves a location, recording it as a miss when it is unknown
+func (g *Geocoder) Lookup(location string) (Coordinates, bool) {
+	key := Normalize(location)
+	g.mu.RLock()
+	c, ok := g.places[key]
+	g.mu.RUnlock()
+	if !ok {
+		g.mu.Lock()
+		g.misses[key]++
+		g.mu.Unlock()
+	}
+	return c, ok
+}
+
+// Find resolves a place typed by a user: a location key, a display name such as
+// "Osaka, Japan" or a bare city name. A city name found in several countries resolves
+// to the first in key order. Unlike Lookup, unknown names are not recorded as misses.
+func (g *Geocoder) Find(name string) (string, Coordinates, bool) {
+	key := Normalize(name)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if c, ok := g.places[key]; ok {
+		return key, c, true
+	}
+
+	var found string
+	for place := range g.places {
+		if i := strings.LastIndex(place, "-"); i >= 0 && place[:i] == key && (found == "" || place < found) {
+			found = place
+		}
+	}
+	if found == "" {
+		return "", Coordinates{}, false
+	}
+	return found, g.places[found], true
+}
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// Distance returns the great-circle distance between two points in kilometres,
+// using the haversine formula
+func Distance(a, b Coordinates) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Len returns the number of known places
+func (g *Geocoder) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.places)
+}
+
+// Misses lists the unresolved locations, most looked up first
+func (g *Geocoder) Misses() []Miss {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	misses := make([]Miss, 0, len(g.misses))
+	for location, count := range g.misses {
+		misses = append(misses, Miss{Location: location, Count: count})
+	}
+	sort.Slice(misses, func(i, j int) bool {
+		if misses[i].Count != misses[j].Count {
+			return misses[i].Count > misses[j].Count
+		}
+		return misses[i].Location < misses[j].Location
+	})
+	return misses
+}
@@ -0,0 +1,121 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"north_carolina-usa":  "north_carolina-usa",
+		"North Carolina, USA": "north_carolina-usa",
+		"  Osaka-Japan ":      "osaka-japan",
+		"São Paulo, Brazil":   "sao_paulo-brazil",
+		"new zealand":         "new_zealand",
+	}
+	for input, expected := range tests {
+		if got := Normalize(input); got != expected {
+			t.Errorf("Normalize(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	g, err := New()
+	if err != nil {
+		t.Fatalf("Could not load the gazetteer: %v", err)
+	}
+	if g.Len() < 100 {
+		t.Errorf("Expected the gazetteer to hold over 100 places, got %d", g.Len())
+	}
+
+	c, ok := g.Lookup("nairobi-kenya")
+	if !ok || c.Lat > -1 || c.Lat < -2 || c.Lon < 36 || c.Lon > 37 {
+		t.Errorf("Expected Nairobi near -1.3, 36.8, got %+v (%v)", c, ok)
+	}
+	if _, ok := g.Lookup("Penrose, New Zealand"); !ok {
+		t.Errorf("Expected display names to resolve")
+	}
+
+	g.Lookup("atlantis-ocean")
+	g.Lookup("atlantis-ocean")
+	g.Lookup("el_dorado-colombia")
+	misses := g.Misses()
+	if len(misses) != 2 || misses[0] != (Miss{"atlantis-ocean", 2}) {
+		t.Errorf("Expected 2 misses, Atlantis first, got %+v", misses)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	g, _ := New()
+	g.Lookup("atlantis-ocean")
+
+	path := filepath.Join(t.TempDir(), "overrides.csv")
+	content := "# local corrections\nlocation,latitude,longitude\natlantis-ocean,36.4,25.4\nnairobi-kenya,0,0\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.LoadOverrides(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c, ok := g.Lookup("atlantis-ocean"); !ok || c.Lat != 36.4 {
+		t.Errorf("Expected the override to resolve Atlantis, got %+v", c)
+	}
+	if c, _ := g.Lookup("nairobi-kenya"); c.Lat != 0 {
+		t.Errorf("Expected overrides to replace gazetteer entries, got %+v", c)
+	}
+	if len(g.Misses()) != 0 {
+		t.Errorf("Expected resolved misses to be cleared, got %+v", g.Misses())
+	}
+
+	if err := g.LoadOverrides(filepath.Join(t.TempDir(), "missing.csv")); err != nil {
+		t.Errorf("Expected a missing overrides file to be ignored, got %v", err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.csv")
+	os.WriteFile(bad, []byte("paris-france,north,east\n"), 0o644)
+	if err := g.LoadOverrides(bad); err == nil {
+		t.Errorf("Expected invalid coordinates to be rejected")
+	}
+}
+
+func TestFind(t *testing.T) {
+	g, _ := New()
+	tests := map[string]string{
+		"nairobi":          "nairobi-kenya",
+		"Nairobi":          "nairobi-kenya",
+		"los angeles":      "los_angeles-usa",
+		"Osaka, Japan":     "osaka-japan",
+		"london-uk":        "london-uk",
+		"playa del carmen": "playa_del_carmen-mexico",
+	}
+	for name, expected := range tests {
+		if key, _, ok := g.Find(name); !ok || key != expected {
+			t.Errorf("Find(%q): expected %q, got %q (%v)", name, expected, key, ok)
+		}
+	}
+
+	if _, _, ok := g.Find("atlantis"); ok {
+		t.Errorf("Expected an unknown city not to be found")
+	}
+	if len(g.Misses()) != 0 {
+		t.Errorf("Expected Find not to record misses, got %+v", g.Misses())
+	}
+}
+
+func TestDistance(t *testing.T) {
+	london := Coordinates{51.5074, -0.1278}
+	paris := Coordinates{48.8566, 2.3522}
+	if d := Distance(london, paris); d < 340 || d > 345 {
+		t.Errorf("Expected London to Paris to be about 343 km, got %.1f", d)
+	}
+	if d := Distance(paris, paris); d != 0 {
+		t.Errorf("Expected a zero distance, got %v", d)
+	}
+	// Antipodal points are half the circumference apart
+	if d := Distance(Coordinates{0, 0}, Coordinates{0, 180}); d < 20014 || d > 20016 {
+		t.Errorf("Expected about 20015 km, got %.1f", d)
+	}
+}
@@ -1,3 +1,10 @@
 module learn.zone01kisumu.ke/git/johnodhiambo0/groupie-tracker
 
 go 1.22.2
+
+require (
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.33.0
+)
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,43 @@
+// Draws the tour map of the artist page: a marker per concert and the tour path
+// between them in chronological order. The map is created the first time the Map
+// tab is opened, because Leaflet cannot measure a hidden container.
+document.addEventListener('DOMContentLoaded', function() {
+    const container = document.getElementById('tour-map');
+    const data = document.getElementById('tour-stops');
+    if (!container || !data || typeof L === 'undefined') {
+        return;
+    }
+    const stops = JSON.parse(data.textContent);
+    let map = null;
+
+    function drawMap() {
+        if (map) {
+            map.invalidateSize();
+            return;
+        }
+        map = L.map(container);
+        L.tileLayer('https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png', {
+            maxZoom: 18,
+            attribution: '&copy; OpenStreetMap contributors'
+        }).addTo(map);
+
+        const path = stops.map(stop => [stop.lat, stop.lon]);
+        stops.forEach(stop => {
+            L.marker([stop.lat, stop.lon])
+                .bindPopup(stop.order + '. ' + stop.name + '<br>' + stop.date)
+                .addTo(map);
+        });
+        if (path.length > 1) {
+            L.polyline(path, { color: '#ff4081', weight: 3 }).addTo(map);
+        }
+        map.fitBounds(L.latLngBounds(path).pad(0.2), { maxZoom: 8 });
+    }
+
+    document.querySelectorAll('.tab').forEach(tab => {
+        tab.addEventListener('click', function() {
+            if (document.getElementById('map').style.display === 'block') {
+                drawMap();
+            }
+        });
+    });
+});